@@ -1,12 +1,41 @@
 package captchago
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
 	"time"
 )
 
+// solutionTextKeys are the solution fields checked in order for the text of the solution
+var solutionTextKeys = []string{
+	"gRecaptchaResponse",
+	"token",
+	"x-kpsdk-cd",
+	"x-kpsdk-ct",
+	"text",
+	"validate",
+	"pass_token",
+	"cookie",
+	"existing_token",
+	"cookies",
+	"sensor_data",
+}
+
+// antiCaptchaError returns a *ServiceError if the response body contains an error, otherwise nil
+func antiCaptchaError(service SolveService, body map[string]interface{}, taskId any) error {
+	errorId, _ := body["errorId"].(float64)
+	code, _ := body["errorCode"].(string)
+	description, _ := body["errorDescription"].(string)
+
+	if errorId == 0 && code == "" && description == "" {
+		return nil
+	}
+
+	return newServiceError(service, code, description, taskId)
+}
+
 func antiCaptchaMethods(solver *Solver, preferredDomain string) *solveMethods {
 	domain := func() string {
 		r := preferredDomain
@@ -26,9 +55,26 @@ func antiCaptchaMethods(solver *Solver, preferredDomain string) *solveMethods {
 		return r
 	}
 
-	createTask := func(task map[string]interface{}) (any, error) {
+	// createTask returns the task id and the response body, which some services already include the solution in
+	createTask := func(ctx context.Context, task map[string]interface{}) (taskId any, body map[string]interface{}, err error) {
 		d := domain()
 
+		charge := chargeFrom(ctx)
+		if err := charge.reserve(); err != nil {
+			return 0, nil, err
+		}
+		defer func() {
+			if err != nil {
+				charge.release()
+			}
+		}()
+
+		breaker := solver.circuit()
+		if err := solver.allowRequest(breaker); err != nil {
+			return 0, nil, err
+		}
+		defer func() { solver.requestDone(breaker, err) }()
+
 		payload := map[string]interface{}{
 			"clientKey": solver.ApiKey,
 			"task":      task,
@@ -42,34 +88,32 @@ func antiCaptchaMethods(solver *Solver, preferredDomain string) *solveMethods {
 			payload["appId"] = "B7E57F27-0AD3-434D-A5B7-CF9EE7D093EF"
 		}
 
-		body, err := postJSON(d+"/createTask", payload)
+		body, err = postJSON(ctx, solver.httpClient(), d+"/createTask", payload)
 		if err != nil {
-			return 0, err
+			return 0, nil, contextError(ctx, nil, err)
 		}
 
-		createErr, hasError := body["errorDescription"]
-		if hasError && createErr != nil {
-			return 0, errors.New(createErr.(string))
+		if err := antiCaptchaError(solver.service, body, nil); err != nil {
+			return 0, nil, err
 		}
 
-		taskId, hasTaskId := body["taskId"]
+		rawId, hasTaskId := body["taskId"]
 		if !hasTaskId {
-			return 0, errors.New("no taskId")
+			return 0, nil, errors.New("no taskId")
 		}
 
-		taskStr, ok := taskId.(string)
+		taskStr, ok := rawId.(string)
 		if ok {
-			return taskStr, nil
+			return taskStr, body, nil
 		}
 
-		return int(taskId.(float64)), nil
+		return int(rawId.(float64)), body, nil
 	}
 
 	// parseResponse returns should continue, solution, error
-	parseResponse := func(body map[string]interface{}) (bool, *Solution, error) {
-		errMsg, hasError := body["errorDescription"]
-		if hasError && errMsg != nil {
-			return false, nil, errors.New(errMsg.(string))
+	parseResponse := func(body map[string]interface{}, taskId any) (bool, *Solution, error) {
+		if err := antiCaptchaError(solver.service, body, taskId); err != nil {
+			return false, nil, err
 		}
 
 		status := body["status"]
@@ -80,30 +124,25 @@ func antiCaptchaMethods(solver *Solver, preferredDomain string) *solveMethods {
 		case "ready":
 			solution, hasSolution := body["solution"].(map[string]interface{})
 			if !hasSolution {
-				return false, nil, errors.New("no solution")
+				return false, nil, malformed("no solution")
 			}
 
-			// response can either be gRecaptchaResponse or token
-			response := solution["gRecaptchaResponse"]
-
-			if response == nil {
-				response = solution["token"]
-			}
-
-			if response == nil {
-				response = solution["x-kpsdk-cd"]
-			}
-
-			if response == nil {
-				response = solution["x-kpsdk-ct"]
+			// the solution text is stored under a different field depending on the task
+			var response interface{}
+			for _, key := range solutionTextKeys {
+				if response = solution[key]; response != nil {
+					break
+				}
 			}
 
 			if response == nil {
-				if solver.Verbose {
-					fmt.Println(body)
+				fields := make([]string, 0, len(solution))
+				for k := range solution {
+					fields = append(fields, k)
 				}
 
-				return false, nil, errors.New("no solution text")
+				solver.log(LogLevelError, "solution has no text", "task_id", taskId, "fields", strings.Join(fields, ","))
+				return false, nil, malformed("no solution text")
 			}
 
 			ip := ""
@@ -128,66 +167,100 @@ func antiCaptchaMethods(solver *Solver, preferredDomain string) *solveMethods {
 				Cost:        cost,
 			}, nil
 		default:
-			return false, nil, errors.New("unknown status")
+			return false, nil, malformed("unknown status")
 		}
 	}
 
-	// keeps retrying until it has returned error or solved
-	getResponse := func(taskId any) (*Solution, error) {
-		for {
-			time.Sleep(solver.UpdateDelay)
+	// pollTask requests the result of a task once, the solution is nil while the task is processing
+	pollTask := func(ctx context.Context, taskId any) (*Solution, error) {
+		d := domain()
 
-			if solver.Verbose {
-				fmt.Println("getting response for task", taskId)
-			}
+		payload := map[string]interface{}{
+			"clientKey": solver.ApiKey,
+			"taskId":    taskId,
+		}
 
-			d := domain()
+		body, err := postJSON(ctx, solver.httpClient(), d+"/getTaskResult", payload)
+		if err != nil {
+			return nil, err
+		}
 
-			payload := map[string]interface{}{
-				"clientKey": solver.ApiKey,
-				"taskId":    taskId,
-			}
+		shouldContinue, sol, err := parseResponse(body, taskId)
+		if err != nil || shouldContinue {
+			return nil, err
+		}
 
-			body, err := postJSON(d+"/getTaskResult", payload)
-			if err != nil {
-				if solver.Verbose {
-					_ = fmt.Errorf("error while getting task result: %s\n", err)
-				}
-				continue
-			}
+		sol.TaskId = taskId
+		return sol, nil
+	}
 
-			shouldContinue, sol, err := parseResponse(body)
-			if err != nil {
-				return nil, err
-			}
+	// invoke sends a task that is solved in a single request, it goes through the circuit breaker like createTask
+	invoke := func(ctx context.Context, endpoint string, payload map[string]interface{}) (sol *Solution, err error) {
+		breaker := solver.circuit()
+		if err := solver.allowRequest(breaker); err != nil {
+			return nil, err
+		}
+		defer func() { solver.requestDone(breaker, err) }()
 
-			if shouldContinue {
-				continue
-			}
+		body, err := postJSON(ctx, solver.httpClient(), domain()+endpoint, payload)
+		if err != nil {
+			return nil, contextError(ctx, nil, err)
+		}
 
-			sol.TaskId = taskId
-			return sol, nil
+		_, sol, err = parseResponse(body, nil)
+		if err == nil && sol == nil {
+			err = malformed("no solution")
 		}
+
+		return sol, err
 	}
 
-	createResponse := func(taskData map[string]interface{}) (*Solution, error) {
-		start := time.Now().UnixMilli()
-		taskId, err := createTask(taskData)
+	createResponse := func(ctx context.Context, taskData map[string]interface{}) (*Solution, error) {
+		start := time.Now()
+		taskType := taskData["type"]
+
+		var created map[string]interface{}
+
+		taskId, err := retryCreate(ctx, solver.retryPolicy(), func() (any, error) {
+			taskId, body, err := createTask(ctx, taskData)
+			created = body
+			return taskId, err
+		})
 		if err != nil {
+			solver.log(LogLevelError, "task failed", "type", taskType, "error", err, "elapsed", time.Since(start))
 			return nil, err
 		}
 
-		if solver.Verbose {
-			fmt.Printf("created task with id %v\n", taskId)
+		solver.log(LogLevelInfo, "task created", "type", taskType, "task_id", taskId)
+
+		var sol *Solution
+
+		// some services answer simple tasks, like image captchas, straight away
+		if created["status"] == "ready" {
+			_, sol, err = parseResponse(created, taskId)
+			if sol != nil {
+				sol.TaskId = taskId
+			}
+		}
+
+		if sub := submissionFrom(ctx); sub != nil && err == nil {
+			sub.taskId = taskId
+			sub.solution = sol
+			return nil, errSubmitted
+		}
+
+		if sol == nil && err == nil {
+			sol, err = solver.waitTask(ctx, taskId, pollTask)
 		}
 
-		sol, err := getResponse(taskId)
 		if sol != nil {
-			sol.Speed = time.Now().UnixMilli() - start
+			sol.Speed = time.Since(start).Milliseconds()
 		}
 
-		if solver.Verbose && err == nil {
-			fmt.Printf("solved task with id %v\n", taskId)
+		if err != nil {
+			solver.log(LogLevelError, "task failed", "type", taskType, "task_id", taskId, "error", err, "elapsed", time.Since(start))
+		} else {
+			solver.log(LogLevelInfo, "task solved", "type", taskType, "task_id", taskId, "elapsed", time.Since(start))
 		}
 
 		return sol, err
@@ -216,21 +289,21 @@ func antiCaptchaMethods(solver *Solver, preferredDomain string) *solveMethods {
 	}
 
 	methods := &solveMethods{
-		GetBalance: func() (float64, error) {
+		domain: domain,
+		GetBalance: func(ctx context.Context) (float64, error) {
 			d := domain()
 
 			payload := map[string]interface{}{
 				"clientKey": solver.ApiKey,
 			}
 
-			body, err := postJSON(d+"/getBalance", payload)
+			body, err := postJSON(ctx, solver.httpClient(), d+"/getBalance", payload)
 			if err != nil {
-				return 0, err
+				return 0, contextError(ctx, nil, err)
 			}
 
-			errMsg, hasError := body["errorDescription"]
-			if hasError && errMsg != nil {
-				return 0, errors.New(errMsg.(string))
+			if err := antiCaptchaError(solver.service, body, nil); err != nil {
+				return 0, err
 			}
 
 			balance, hasBalance := body["balance"]
@@ -240,7 +313,7 @@ func antiCaptchaMethods(solver *Solver, preferredDomain string) *solveMethods {
 
 			return balance.(float64), nil
 		},
-		RecaptchaV2: func(o RecaptchaV2Options) (*Solution, error) {
+		RecaptchaV2: func(ctx context.Context, o RecaptchaV2Options) (*Solution, error) {
 			taskData := map[string]interface{}{
 				"websiteURL":  o.PageURL,
 				"websiteKey":  o.SiteKey,
@@ -270,9 +343,9 @@ func antiCaptchaMethods(solver *Solver, preferredDomain string) *solveMethods {
 				taskData["recaptchaDataSValue"] = o.DataS
 			}
 
-			return createResponse(taskData)
+			return createResponse(ctx, taskData)
 		},
-		HCaptcha: func(o HCaptchaOptions) (*Solution, error) {
+		HCaptcha: func(ctx context.Context, o HCaptchaOptions) (*Solution, error) {
 			taskData := map[string]interface{}{
 				"websiteURL":  o.PageURL,
 				"websiteKey":  o.SiteKey,
@@ -334,9 +407,9 @@ func antiCaptchaMethods(solver *Solver, preferredDomain string) *solveMethods {
 
 			}
 
-			return createResponse(taskData)
+			return createResponse(ctx, taskData)
 		},
-		FunCaptcha: func(o FunCaptchaOptions) (*Solution, error) {
+		FunCaptcha: func(ctx context.Context, o FunCaptchaOptions) (*Solution, error) {
 			taskData := map[string]interface{}{
 				"websiteURL":               o.PageURL,
 				"websitePublicKey":         o.PublicKey,
@@ -347,9 +420,9 @@ func antiCaptchaMethods(solver *Solver, preferredDomain string) *solveMethods {
 
 			taskData["userAgent"] = o.UserAgent
 
-			return createResponse(taskData)
+			return createResponse(ctx, taskData)
 		},
-		RecaptchaV3: func(o RecaptchaV3Options) (*Solution, error) {
+		RecaptchaV3: func(ctx context.Context, o RecaptchaV3Options) (*Solution, error) {
 			taskData := map[string]interface{}{
 				"type":         "RecaptchaV3TaskProxyless",
 				"websiteURL":   o.PageURL,
@@ -359,13 +432,198 @@ func antiCaptchaMethods(solver *Solver, preferredDomain string) *solveMethods {
 				"isEnterprise": o.Enterprise,
 			}
 
-			return createResponse(taskData)
+			return createResponse(ctx, taskData)
+		},
+		GeeTest: func(ctx context.Context, o GeeTestOptions) (*GeeTestSolution, error) {
+			taskData := map[string]interface{}{
+				"websiteURL": o.PageURL,
+				"gt":         o.GT,
+				"challenge":  o.Challenge,
+			}
+
+			applyProxy(taskData, o.Proxy, "GeeTestTask")
+
+			if o.APIServer != "" {
+				taskData["geetestApiServerSubdomain"] = o.APIServer
+			}
+
+			if o.UserAgent != "" {
+				taskData["userAgent"] = o.UserAgent
+			}
+
+			sol, err := createResponse(ctx, taskData)
+			if err != nil {
+				return nil, err
+			}
+
+			return &GeeTestSolution{
+				Solution:  sol,
+				Challenge: rawString(sol.RawSolution, "challenge"),
+				Validate:  rawString(sol.RawSolution, "validate"),
+				Seccode:   rawString(sol.RawSolution, "seccode"),
+			}, nil
+		},
+		GeeTestV4: func(ctx context.Context, o GeeTestV4Options) (*GeeTestV4Solution, error) {
+			initParameters := map[string]interface{}{
+				"captcha_id": o.CaptchaID,
+			}
+
+			for k, v := range o.InitParameters {
+				initParameters[k] = v
+			}
+
+			taskData := map[string]interface{}{
+				"websiteURL":     o.PageURL,
+				"gt":             o.CaptchaID,
+				"version":        4,
+				"initParameters": initParameters,
+			}
+
+			// capsolver.com reads the captcha id from its own field
+			if solver.service == CapSolver {
+				taskData["captchaId"] = o.CaptchaID
+			}
+
+			applyProxy(taskData, o.Proxy, "GeeTestTask")
+
+			if o.APIServer != "" {
+				taskData["geetestApiServerSubdomain"] = o.APIServer
+			}
+
+			if o.UserAgent != "" {
+				taskData["userAgent"] = o.UserAgent
+			}
+
+			sol, err := createResponse(ctx, taskData)
+			if err != nil {
+				return nil, err
+			}
+
+			return newGeeTestV4Solution(sol, o.CaptchaID), nil
+		},
+		AmazonWAF: func(ctx context.Context, o AmazonWAFOptions) (*Solution, error) {
+			taskData := map[string]interface{}{
+				"websiteURL": o.PageURL,
+			}
+
+			// capsolver.com uses its own task and field names
+			if solver.service == CapSolver {
+				applyProxy(taskData, o.Proxy, "AntiAwsWafTask")
+				if o.Proxy == nil {
+					taskData["type"] = "AntiAwsWafTaskProxyLess"
+				}
+
+				taskData["awsKey"] = o.SiteKey
+				taskData["awsIv"] = o.Iv
+				taskData["awsContext"] = o.Context
+				taskData["awsChallengeJS"] = o.ChallengeScript
+			} else {
+				applyProxy(taskData, o.Proxy, "AmazonTask")
+
+				taskData["websiteKey"] = o.SiteKey
+				taskData["iv"] = o.Iv
+				taskData["context"] = o.Context
+				taskData["challengeScript"] = o.ChallengeScript
+
+				if o.CaptchaScript != "" {
+					taskData["captchaScript"] = o.CaptchaScript
+				}
+			}
+
+			if o.UserAgent != "" {
+				taskData["userAgent"] = o.UserAgent
+			}
+
+			sol, err := createResponse(ctx, taskData)
+			if err != nil {
+				return nil, err
+			}
+
+			return amazonWAFSolution(sol)
+		},
+		ImageToText: func(ctx context.Context, o ImageCaptchaOptions) (*Solution, error) {
+			image, err := o.base64()
+			if err != nil {
+				return nil, err
+			}
+
+			taskData := map[string]interface{}{
+				"type":    "ImageToTextTask",
+				"body":    image,
+				"phrase":  o.Phrase,
+				"case":    o.CaseSensitive,
+				"numeric": o.Numeric,
+				"math":    o.Math,
+			}
+
+			if o.MinLength > 0 {
+				taskData["minLength"] = o.MinLength
+			}
+
+			if o.MaxLength > 0 {
+				taskData["maxLength"] = o.MaxLength
+			}
+
+			if o.Language != "" {
+				taskData["languagePool"] = o.Language
+			}
+
+			if o.Comment != "" {
+				taskData["comment"] = o.Comment
+			}
+
+			return createResponse(ctx, taskData)
 		},
 	}
 
-	// kasada method
+	methods.TaskResult = pollTask
+
+	// capmonster and anycaptcha don't take reports, ReportBad and ReportGood return an UnsupportedError for them
+	if solver.service == AntiCaptcha || solver.service == CapSolver {
+		methods.Report = func(ctx context.Context, sol *Solution, correct bool) error {
+			payload := map[string]interface{}{
+				"clientKey": solver.ApiKey,
+				"taskId":    sol.TaskId,
+			}
+
+			var endpoint string
+
+			if solver.service == CapSolver {
+				// capsolver.com takes every report on the same endpoint
+				endpoint = "/feedbackTask"
+				payload["appId"] = "B7E57F27-0AD3-434D-A5B7-CF9EE7D093EF"
+				payload["result"] = map[string]interface{}{
+					"invalid": !correct,
+				}
+			} else {
+				switch {
+				case sol.Type == CaptchaRecaptchaV2 || sol.Type == CaptchaRecaptchaV3:
+					if correct {
+						endpoint = "/reportCorrectRecaptcha"
+					} else {
+						endpoint = "/reportIncorrectRecaptcha"
+					}
+				case sol.Type == CaptchaHCaptcha && !correct:
+					endpoint = "/reportIncorrectHcaptcha"
+				case sol.Type == CaptchaImage && !correct:
+					endpoint = "/reportIncorrectImageCaptcha"
+				default:
+					return solver.unsupported(reportMethod(sol.Type, correct))
+				}
+			}
+
+			body, err := postJSON(ctx, solver.httpClient(), domain()+endpoint, payload)
+			if err != nil {
+				return contextError(ctx, sol.TaskId, err)
+			}
+
+			return antiCaptchaError(solver.service, body, sol.TaskId)
+		}
+	}
+
+	// anti-bot methods only capsolver.com supports
 	if solver.service == CapSolver {
-		methods.Kasada = func(o KasadaOptions) (*KasadaSolution, error) {
+		methods.Kasada = func(ctx context.Context, o KasadaOptions) (*KasadaSolution, error) {
 			if o.Proxy == nil {
 				return nil, errors.New("proxy is required")
 			}
@@ -393,20 +651,19 @@ func antiCaptchaMethods(solver *Solver, preferredDomain string) *solveMethods {
 				"appId":     "B7E57F27-0AD3-434D-A5B7-CF9EE7D093EF",
 			}
 
-			body, err := postJSON(domain()+"/kasada/invoke", payload)
-			if err != nil {
+			charge := chargeFrom(ctx)
+			if err := charge.reserve(); err != nil {
 				return nil, err
 			}
 
-			_, sol, err := parseResponse(body)
+			sol, err := retryCreate(ctx, solver.retryPolicy(), func() (*Solution, error) {
+				return invoke(ctx, "/kasada/invoke", payload)
+			})
 			if err != nil {
+				charge.release()
 				return nil, err
 			}
 
-			if sol == nil {
-				return nil, errors.New("no solution")
-			}
-
 			kpsdkCD := ""
 			kpsdkCT := ""
 			userAgent := ""
@@ -434,7 +691,60 @@ func antiCaptchaMethods(solver *Solver, preferredDomain string) *solveMethods {
 			}, nil
 		}
 
-		methods.Cloudflare = func(o CloudflareOptions) (*Solution, error) {
+		methods.Akamai = func(ctx context.Context, o AkamaiOptions) (*AkamaiSolution, error) {
+			if o.Proxy == nil {
+				return nil, errors.New("proxy is required")
+			}
+
+			taskData := map[string]interface{}{
+				"websiteURL": o.PageURL,
+				"scriptUrl":  o.ScriptURL,
+			}
+
+			applyProxy(taskData, o.Proxy, "AntiAkamaiWebTask")
+
+			if o.Abck != "" {
+				taskData["abck"] = o.Abck
+			}
+
+			if o.BmSz != "" {
+				taskData["bmsz"] = o.BmSz
+			}
+
+			if o.UserAgent != "" {
+				taskData["userAgent"] = o.UserAgent
+			}
+
+			sol, err := createResponse(ctx, taskData)
+			if err != nil {
+				return nil, err
+			}
+
+			return newAkamaiSolution(sol, o), nil
+		}
+
+		methods.DataDome = func(ctx context.Context, o DataDomeOptions) (*Solution, error) {
+			if o.Proxy == nil {
+				return nil, errors.New("proxy is required")
+			}
+
+			taskData := map[string]interface{}{
+				"websiteURL": o.PageURL,
+				"captchaUrl": o.CaptchaURL,
+				"userAgent":  o.UserAgent,
+			}
+
+			applyProxy(taskData, o.Proxy, "DataDomeSliderTask")
+
+			sol, err := createResponse(ctx, taskData)
+			if err != nil {
+				return nil, err
+			}
+
+			return dataDomeSolution(sol)
+		}
+
+		methods.Cloudflare = func(ctx context.Context, o CloudflareOptions) (*Solution, error) {
 			if o.Proxy == nil {
 				return nil, errors.New("proxy is required")
 			}
@@ -474,12 +784,12 @@ func antiCaptchaMethods(solver *Solver, preferredDomain string) *solveMethods {
 
 			applyProxy(taskData, o.Proxy, "AntiCloudflareTask")
 
-			return createResponse(taskData)
+			return createResponse(ctx, taskData)
 		}
 	} else {
-		methods.Cloudflare = func(o CloudflareOptions) (*Solution, error) {
+		methods.Cloudflare = func(ctx context.Context, o CloudflareOptions) (*Solution, error) {
 			if o.Type == CloudflareTypeChallenge {
-				return nil, errors.New("cloudflare challenge type is not supported by this solver")
+				return nil, solver.unsupported("cloudflare challenges")
 			}
 
 			taskData := map[string]interface{}{
@@ -499,7 +809,7 @@ func antiCaptchaMethods(solver *Solver, preferredDomain string) *solveMethods {
 
 			applyProxy(taskData, o.Proxy, "TurnstileTask")
 
-			return createResponse(taskData)
+			return createResponse(ctx, taskData)
 		}
 	}
 
@@ -0,0 +1,260 @@
+package captchago
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// NewBalanceMonitor starts polling the balance of the solver's account in the background.
+// Close must be called once the monitor isn't used anymore
+func NewBalanceMonitor(s *Solver, opts BalanceMonitorOptions) *BalanceMonitor {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Minute * 5
+	}
+
+	if opts.Window <= 0 {
+		opts.Window = time.Hour * 6
+	}
+
+	thresholds := make([]float64, len(opts.Thresholds))
+	copy(thresholds, opts.Thresholds)
+	sort.Sort(sort.Reverse(sort.Float64Slice(thresholds)))
+	opts.Thresholds = thresholds
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m := &BalanceMonitor{
+		solver: s,
+		opts:   opts,
+		ctx:    ctx,
+		cancel: cancel,
+		fired:  make(map[float64]bool),
+	}
+
+	m.wg.Add(1)
+	go m.run()
+
+	return m
+}
+
+// Balance returns the last balance polled and when, zero values until the first poll succeeded
+func (m *BalanceMonitor) Balance() (float64, time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.samples) == 0 {
+		return 0, time.Time{}
+	}
+
+	last := m.samples[len(m.samples)-1]
+	return last.balance, last.at
+}
+
+// Trend returns how much the balance changed per hour over the window, top ups included.
+// It's negative while the account is being spent
+func (m *BalanceMonitor) Trend() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.samples) < 2 {
+		return 0
+	}
+
+	first, last := m.samples[0], m.samples[len(m.samples)-1]
+	hours := last.at.Sub(first.at).Hours()
+	if hours <= 0 {
+		return 0
+	}
+
+	return (last.balance - first.balance) / hours
+}
+
+// SpendRate returns how much was spent per hour over the window, top ups are ignored
+func (m *BalanceMonitor) SpendRate() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.spendRate()
+}
+
+// RunwayHours estimates how many hours the balance lasts at the current spend rate, +Inf if nothing is being spent
+func (m *BalanceMonitor) RunwayHours() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rate := m.spendRate()
+	if rate <= 0 || len(m.samples) == 0 {
+		return math.Inf(1)
+	}
+
+	balance := m.samples[len(m.samples)-1].balance
+	if balance <= 0 {
+		return 0
+	}
+
+	return balance / rate
+}
+
+// Err returns the error of the last poll, nil if it succeeded
+func (m *BalanceMonitor) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.err
+}
+
+// Close stops polling the balance
+func (m *BalanceMonitor) Close() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// spendRate sums the drops of the balance over the window, m.mu must be held
+func (m *BalanceMonitor) spendRate() float64 {
+	if len(m.samples) < 2 {
+		return 0
+	}
+
+	spent := 0.0
+	for i := 1; i < len(m.samples); i++ {
+		if drop := m.samples[i-1].balance - m.samples[i].balance; drop > 0 {
+			spent += drop
+		}
+	}
+
+	hours := m.samples[len(m.samples)-1].at.Sub(m.samples[0].at).Hours()
+	if hours <= 0 {
+		return 0
+	}
+
+	return spent / hours
+}
+
+func (m *BalanceMonitor) run() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.poll()
+
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll requests the balance once and fires the callbacks it crossed
+func (m *BalanceMonitor) poll() {
+	balance, err := m.solver.GetBalanceContext(m.ctx)
+	if err != nil {
+		if m.ctx.Err() != nil {
+			return
+		}
+
+		m.mu.Lock()
+		m.err = err
+		m.mu.Unlock()
+
+		m.solver.log(LogLevelWarn, "balance poll failed", "error", err)
+		if m.opts.OnError != nil {
+			m.opts.OnError(err)
+		}
+		return
+	}
+
+	now := time.Now()
+
+	m.mu.Lock()
+	m.err = nil
+	m.samples = append(m.samples, balanceSample{at: now, balance: balance})
+
+	i := 0
+	for i < len(m.samples)-1 && now.Sub(m.samples[i].at) > m.opts.Window {
+		i++
+	}
+	m.samples = m.samples[i:]
+
+	// every callback fires once when the balance drops below it, and again after a top up
+	var low []float64
+	for _, threshold := range m.opts.Thresholds {
+		if balance >= threshold {
+			m.fired[threshold] = false
+		} else if !m.fired[threshold] {
+			m.fired[threshold] = true
+			low = append(low, threshold)
+		}
+	}
+
+	depleted := false
+	if balance > 0 {
+		m.depleted = false
+	} else if !m.depleted {
+		m.depleted = true
+		depleted = true
+	}
+	m.mu.Unlock()
+
+	m.solver.log(LogLevelDebug, "balance polled", "balance", balance)
+
+	for _, threshold := range low {
+		m.solver.log(LogLevelWarn, "balance low", "balance", balance, "threshold", threshold)
+		if m.opts.OnLow != nil {
+			m.opts.OnLow(threshold, balance)
+		}
+	}
+
+	if depleted {
+		m.solver.log(LogLevelError, "balance depleted", "balance", balance)
+		if m.opts.OnDepleted != nil {
+			m.opts.OnDepleted(balance)
+		}
+	}
+}
+
+// BalanceMonitor polls the balance of an account, see NewBalanceMonitor
+type BalanceMonitor struct {
+	solver *Solver
+	opts   BalanceMonitorOptions
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	samples  []balanceSample
+	fired    map[float64]bool
+	depleted bool
+	err      error
+}
+
+type BalanceMonitorOptions struct {
+	// Interval is the time between polls, defaults to 5 minutes
+	Interval time.Duration
+
+	// Window is how far back the trend, spend rate and runway look, defaults to 6 hours
+	Window time.Duration
+
+	// Thresholds are the balances OnLow fires at
+	Thresholds []float64
+
+	// OnLow is called once when the balance drops below a threshold, and again if it drops below it after a top up
+	OnLow func(threshold, balance float64)
+
+	// OnDepleted is called once when the balance reaches zero, and again if it does after a top up
+	OnDepleted func(balance float64)
+
+	// OnError is called when polling the balance fails
+	OnError func(err error)
+}
+
+type balanceSample struct {
+	at      time.Time
+	balance float64
+}
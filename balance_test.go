@@ -0,0 +1,126 @@
+package captchago_test
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/median/captchago"
+)
+
+func TestBalanceMonitor(t *testing.T) {
+	srv := newServer(t)
+	srv.SetBalance(10)
+
+	var mu sync.Mutex
+	var low []float64
+	depleted := 0
+
+	m := captchago.NewBalanceMonitor(srv.Solver(captchago.AntiCaptcha), captchago.BalanceMonitorOptions{
+		Interval:   time.Millisecond * 5,
+		Thresholds: []float64{1, 5},
+		OnLow: func(threshold, balance float64) {
+			mu.Lock()
+			low = append(low, threshold)
+			mu.Unlock()
+		},
+		OnDepleted: func(balance float64) {
+			mu.Lock()
+			depleted++
+			mu.Unlock()
+		},
+	})
+	defer m.Close()
+
+	waitBalance := func(want float64) {
+		t.Helper()
+
+		deadline := time.Now().Add(time.Second * 5)
+		for {
+			balance, at := m.Balance()
+			if balance == want && !at.IsZero() {
+				// one more poll so callbacks of the balance have fired
+				time.Sleep(time.Millisecond * 20)
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("balance %v, want %v", balance, want)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	waitBalance(10)
+
+	srv.SetBalance(3)
+	waitBalance(3)
+
+	srv.SetBalance(0)
+	waitBalance(0)
+
+	// a top up re-arms the callbacks
+	srv.SetBalance(20)
+	waitBalance(20)
+
+	srv.SetBalance(4)
+	waitBalance(4)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	want := []float64{5, 1, 5}
+	if len(low) != len(want) {
+		t.Fatalf("OnLow fired for %v, want %v", low, want)
+	}
+	for i := range want {
+		if low[i] != want[i] {
+			t.Fatalf("OnLow fired for %v, want %v", low, want)
+		}
+	}
+
+	if depleted != 1 {
+		t.Errorf("OnDepleted fired %d times, want 1", depleted)
+	}
+
+	if m.Err() != nil {
+		t.Errorf("Err() returned %v", m.Err())
+	}
+
+	if m.SpendRate() <= 0 || math.IsInf(m.RunwayHours(), 1) {
+		t.Errorf("spend rate %v and runway %v after spending", m.SpendRate(), m.RunwayHours())
+	}
+}
+
+func TestBalanceMonitorError(t *testing.T) {
+	srv := newServer(t)
+
+	solver := srv.Solver(captchago.AntiCaptcha)
+	solver.ApiKey = "wrong-key"
+
+	errs := make(chan error, 10)
+	m := captchago.NewBalanceMonitor(solver, captchago.BalanceMonitorOptions{
+		Interval: time.Millisecond * 5,
+		OnError: func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		},
+	})
+	defer m.Close()
+
+	select {
+	case <-errs:
+	case <-time.After(time.Second * 5):
+		t.Fatal("OnError wasn't called")
+	}
+
+	if m.Err() == nil {
+		t.Error("Err() returned nil")
+	}
+
+	if !math.IsInf(m.RunwayHours(), 1) {
+		t.Errorf("runway %v without any balance", m.RunwayHours())
+	}
+}
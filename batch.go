@@ -0,0 +1,87 @@
+package captchago
+
+import (
+	"context"
+	"sync"
+)
+
+// SolveBatch solves every task with at most o.Concurrency running at once.
+// The results are in the same order as tasks, each with its own error
+func (s *Solver) SolveBatch(ctx context.Context, tasks []TaskOptions, o BatchOptions) []BatchResult {
+	return solveBatch(ctx, tasks, o, s.Solve)
+}
+
+func solveBatch(ctx context.Context, tasks []TaskOptions, o BatchOptions, solve func(context.Context, TaskOptions) (*Solution, error)) []BatchResult {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	concurrency := o.Concurrency
+	if concurrency < 1 || concurrency > len(tasks) {
+		concurrency = len(tasks)
+	}
+
+	results := make([]BatchResult, len(tasks))
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	finished := 0
+
+	for i, task := range tasks {
+		results[i].Index = i
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Err = contextError(ctx, nil, ctx.Err())
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, task TaskOptions) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			sol, err := solve(ctx, task)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			results[i].Solution = sol
+			results[i].Err = err
+			finished++
+
+			if err != nil && o.StopOnError {
+				cancel()
+			}
+
+			if o.OnProgress != nil {
+				o.OnProgress(finished, len(tasks), results[i])
+			}
+		}(i, task)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// BatchOptions are the options for Solver.SolveBatch
+type BatchOptions struct {
+	// Concurrency is the max number of tasks solved at once, 0 solves every task at once
+	Concurrency int
+
+	// StopOnError cancels every remaining task once one fails
+	StopOnError bool
+
+	// OnProgress is called after every task finishes, calls never overlap
+	OnProgress func(finished, total int, result BatchResult)
+}
+
+type BatchResult struct {
+	// Index is the position of the task in the batch
+	Index int
+
+	// Solution is nil if Err is set
+	Solution *Solution
+	Err      error
+}
@@ -0,0 +1,84 @@
+package captchago_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/median/captchago"
+	"github.com/median/captchago/captchagotest"
+)
+
+func TestSolveBatch(t *testing.T) {
+	srv := newServer(t)
+	srv.Script(captchago.CaptchaHCaptcha, captchagotest.FailWith(1, "ERROR_CAPTCHA_UNSOLVABLE"))
+
+	solver := srv.Solver(captchago.AntiCaptcha)
+	solver.RetryPolicy.CreateAttempts = 1
+
+	tasks := []captchago.TaskOptions{
+		captchago.RecaptchaV2Options{PageURL: "https://example.com", SiteKey: "site-key"},
+		captchago.HCaptchaOptions{PageURL: "https://example.com", SiteKey: "site-key"},
+		captchago.ImageCaptchaOptions{Image: []byte("image")},
+	}
+
+	var progress []int
+	results := solver.SolveBatch(context.Background(), tasks, captchago.BatchOptions{
+		Concurrency: 2,
+		OnProgress: func(finished, total int, result captchago.BatchResult) {
+			if total != len(tasks) {
+				t.Errorf("total %d, want %d", total, len(tasks))
+			}
+			progress = append(progress, finished)
+		},
+	})
+
+	if len(results) != len(tasks) {
+		t.Fatalf("%d results, want %d", len(results), len(tasks))
+	}
+
+	for i, r := range results {
+		if r.Index != i {
+			t.Errorf("result %d has index %d", i, r.Index)
+		}
+	}
+
+	if results[0].Err != nil || results[2].Err != nil {
+		t.Errorf("errors: %v, %v", results[0].Err, results[2].Err)
+	}
+
+	if !errors.Is(results[1].Err, captchago.ErrUnsolvable) {
+		t.Errorf("got %v, want ErrUnsolvable", results[1].Err)
+	}
+
+	if len(progress) != len(tasks) || progress[len(progress)-1] != len(tasks) {
+		t.Errorf("progress %v", progress)
+	}
+}
+
+func TestSolveBatchStopOnError(t *testing.T) {
+	srv := newServer(t)
+	srv.Script(captchago.CaptchaHCaptcha, captchagotest.FailCreate("ERROR_ZERO_BALANCE"))
+	srv.SetDefault(captchagotest.SolveAfter(1000))
+
+	tasks := []captchago.TaskOptions{
+		captchago.HCaptchaOptions{PageURL: "https://example.com", SiteKey: "site-key"},
+		captchago.RecaptchaV2Options{PageURL: "https://example.com", SiteKey: "site-key"},
+		captchago.RecaptchaV2Options{PageURL: "https://example.com", SiteKey: "site-key"},
+	}
+
+	results := srv.Solver(captchago.AntiCaptcha).SolveBatch(context.Background(), tasks, captchago.BatchOptions{
+		Concurrency: 1,
+		StopOnError: true,
+	})
+
+	if !errors.Is(results[0].Err, captchago.ErrZeroBalance) {
+		t.Errorf("got %v, want ErrZeroBalance", results[0].Err)
+	}
+
+	for _, r := range results[1:] {
+		if !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("task %d: got %v, want context.Canceled", r.Index, r.Err)
+		}
+	}
+}
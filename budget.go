@@ -0,0 +1,283 @@
+package captchago
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultTaskEstimate is what a task is charged when it's created if Solver.Prices has no price for its type
+// and Budget.Estimate is 0. It's above the price of most captcha types, so concurrent solves don't overshoot the limits
+const DefaultTaskEstimate = 0.01
+
+// Budget caps what solvers may spend. Set it on Solver.Budget, the same budget can be shared by several solvers.
+// Tasks are charged their price from Solver.Prices, or Estimate, when they are created, and the charge is corrected
+// to the cost reported by the service once they are solved. Failed tasks are charged nothing.
+// Once a limit is reached new tasks fail with ErrBudgetExceeded before they are sent to the service
+type Budget struct {
+	BudgetLimits
+
+	// Estimate is what a task is charged when it's created if Solver.Prices has no price for its type,
+	// DefaultTaskEstimate if 0. Use the price of the most expensive captcha type solved to never go over a limit
+	Estimate float64
+
+	// Labels limits the tasks solved with a context from WithBudgetLabel, on top of the limits of the budget
+	Labels map[string]BudgetLimits
+
+	mu      sync.Mutex
+	entries []*budgetEntry
+}
+
+// BudgetLimits are the limits of a budget, a zero value means no limit
+type BudgetLimits struct {
+	MaxSpendPerHour float64
+	MaxSpendPerDay  float64
+
+	// MaxTasks is the max number of tasks created in TaskWindow
+	MaxTasks int
+
+	// TaskWindow is the window MaxTasks counts tasks in, an hour if 0
+	TaskWindow time.Duration
+}
+
+// WithBudgetLabel returns a context that charges the tasks solved with it to label, see Budget.Labels
+func WithBudgetLabel(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, budgetLabelKey{}, label)
+}
+
+// Spent returns what was spent in the last window, by label or in total if label is ""
+func (b *Budget) Spent(label string, window time.Duration) float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	spent, _ := b.usage(label, window, time.Now())
+	return spent
+}
+
+// Tasks returns the number of tasks created in the last window, by label or in total if label is ""
+func (b *Budget) Tasks(label string, window time.Duration) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, tasks := b.usage(label, window, time.Now())
+	return tasks
+}
+
+type budgetEntry struct {
+	at    time.Time
+	label string
+	cost  float64
+}
+
+type budgetLabelKey struct{}
+
+// usage sums the entries of the last window, b.mu must be held
+func (b *Budget) usage(label string, window time.Duration, now time.Time) (spent float64, tasks int) {
+	for i := len(b.entries) - 1; i >= 0 && now.Sub(b.entries[i].at) <= window; i-- {
+		if label == "" || b.entries[i].label == label {
+			spent += b.entries[i].cost
+			tasks++
+		}
+	}
+
+	return spent, tasks
+}
+
+// check returns an error wrapping ErrBudgetExceeded if a task costing cost would go over limits, b.mu must be held
+func (b *Budget) check(limits BudgetLimits, label string, cost float64, now time.Time) error {
+	name := "budget"
+	if label != "" {
+		name = "budget " + label
+	}
+
+	over := func(spent, max float64) bool {
+		return max > 0 && (spent >= max || spent+cost > max)
+	}
+
+	if spent, _ := b.usage(label, time.Hour, now); over(spent, limits.MaxSpendPerHour) {
+		return fmt.Errorf("%w: %s spent %.4f of %.4f in the last hour", ErrBudgetExceeded, name, spent, limits.MaxSpendPerHour)
+	}
+
+	if spent, _ := b.usage(label, time.Hour*24, now); over(spent, limits.MaxSpendPerDay) {
+		return fmt.Errorf("%w: %s spent %.4f of %.4f in the last day", ErrBudgetExceeded, name, spent, limits.MaxSpendPerDay)
+	}
+
+	if limits.MaxTasks > 0 {
+		window := limits.taskWindow()
+		if _, tasks := b.usage(label, window, now); tasks >= limits.MaxTasks {
+			return fmt.Errorf("%w: %s created %d of %d tasks in the last %s", ErrBudgetExceeded, name, tasks, limits.MaxTasks, window)
+		}
+	}
+
+	return nil
+}
+
+func (l BudgetLimits) taskWindow() time.Duration {
+	if l.TaskWindow > 0 {
+		return l.TaskWindow
+	}
+	return time.Hour
+}
+
+// reserve adds an entry for a new task, or returns an error if it would go over a limit
+func (b *Budget) reserve(label string, cost float64) (*budgetEntry, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.trim(now)
+
+	if err := b.check(b.BudgetLimits, "", cost, now); err != nil {
+		return nil, err
+	}
+
+	if limits, ok := b.Labels[label]; ok && label != "" {
+		if err := b.check(limits, label, cost, now); err != nil {
+			return nil, err
+		}
+	}
+
+	entry := &budgetEntry{at: now, label: label, cost: cost}
+	b.entries = append(b.entries, entry)
+
+	return entry, nil
+}
+
+// trim drops the entries no limit looks at anymore, b.mu must be held
+func (b *Budget) trim(now time.Time) {
+	horizon := time.Hour * 24
+	if w := b.taskWindow(); w > horizon {
+		horizon = w
+	}
+	for _, limits := range b.Labels {
+		if w := limits.taskWindow(); w > horizon {
+			horizon = w
+		}
+	}
+
+	drop := 0
+	for drop < len(b.entries) && now.Sub(b.entries[drop].at) > horizon {
+		drop++
+	}
+
+	b.entries = b.entries[drop:]
+}
+
+// remove takes back the entry of a task that couldn't be created
+func (b *Budget) remove(entry *budgetEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, e := range b.entries {
+		if e == entry {
+			b.entries = append(b.entries[:i], b.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+func (b *Budget) setCost(entry *budgetEntry, cost float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry.cost = cost
+}
+
+// budgetCharge follows one solve from the creation of its task to its solution
+type budgetCharge struct {
+	budget   *Budget
+	label    string
+	estimate float64
+
+	mu    sync.Mutex
+	entry *budgetEntry
+}
+
+type chargeKey struct{}
+
+// newCharge returns the charge for a solve of captchaType, nil if the solver has no budget
+func (s *Solver) newCharge(ctx context.Context, captchaType CaptchaType) *budgetCharge {
+	if s.Budget == nil {
+		return nil
+	}
+
+	label, _ := ctx.Value(budgetLabelKey{}).(string)
+
+	estimate, ok := s.Prices[captchaType]
+	if !ok {
+		estimate = s.Budget.Estimate
+		if estimate <= 0 {
+			estimate = DefaultTaskEstimate
+		}
+	}
+
+	return &budgetCharge{
+		budget:   s.Budget,
+		label:    label,
+		estimate: estimate,
+	}
+}
+
+func chargeFrom(ctx context.Context) *budgetCharge {
+	charge, _ := ctx.Value(chargeKey{}).(*budgetCharge)
+	return charge
+}
+
+// reserve charges the estimate before the task is created, retries of the same task are only charged once
+func (c *budgetCharge) reserve() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entry != nil {
+		return nil
+	}
+
+	entry, err := c.budget.reserve(c.label, c.estimate)
+	if err != nil {
+		return err
+	}
+
+	c.entry = entry
+	return nil
+}
+
+// release takes back the charge when the task couldn't be created
+func (c *budgetCharge) release() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entry != nil {
+		c.budget.remove(c.entry)
+		c.entry = nil
+	}
+}
+
+// settle corrects the charge to what the task actually cost once it's solved, or to 0 if it failed.
+// Solved tasks with an unknown cost keep the estimate
+func (c *budgetCharge) settle(cost float64, err error) {
+	if c == nil {
+		return
+	}
+
+	if err != nil {
+		cost = 0
+	} else if cost == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entry != nil {
+		c.budget.setCost(c.entry, cost)
+	}
+}
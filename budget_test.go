@@ -0,0 +1,155 @@
+package captchago_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/median/captchago"
+	"github.com/median/captchago/captchagotest"
+)
+
+var budgetTask = captchago.RecaptchaV2Options{PageURL: "https://example.com", SiteKey: "site-key"}
+
+func TestBudgetMaxTasks(t *testing.T) {
+	srv := newServer(t)
+
+	solver := srv.Solver(captchago.AntiCaptcha)
+	solver.Budget = &captchago.Budget{BudgetLimits: captchago.BudgetLimits{MaxTasks: 2}}
+
+	for i := 0; i < 2; i++ {
+		if _, err := solver.RecaptchaV2(budgetTask); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := solver.RecaptchaV2(budgetTask)
+	if !errors.Is(err, captchago.ErrBudgetExceeded) {
+		t.Fatalf("got %v, want ErrBudgetExceeded", err)
+	}
+
+	if n := len(srv.Tasks()); n != 2 {
+		t.Errorf("%d tasks sent, want 2", n)
+	}
+}
+
+func TestBudgetConcurrentEstimate(t *testing.T) {
+	srv := newServer(t)
+	srv.SetDefault(captchagotest.SolveAfter(20))
+
+	// no prices are set, every task is reserved at DefaultTaskEstimate until it's solved
+	solver := srv.Solver(captchago.AntiCaptcha)
+	solver.Budget = &captchago.Budget{BudgetLimits: captchago.BudgetLimits{MaxSpendPerHour: captchago.DefaultTaskEstimate * 3}}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	exceeded := 0
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := solver.RecaptchaV2(budgetTask)
+			if errors.Is(err, captchago.ErrBudgetExceeded) {
+				mu.Lock()
+				exceeded++
+				mu.Unlock()
+			} else if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if exceeded != 2 {
+		t.Errorf("%d solves exceeded the budget, want 2", exceeded)
+	}
+}
+
+func TestBudgetSettle(t *testing.T) {
+	srv := newServer(t)
+	srv.Script(captchago.CaptchaRecaptchaV2, captchagotest.Outcome{Cost: "0.002"}, captchagotest.FailWith(0, "ERROR_CAPTCHA_UNSOLVABLE"))
+
+	solver := srv.Solver(captchago.AntiCaptcha)
+	solver.RetryPolicy.CreateAttempts = 1
+	solver.Budget = &captchago.Budget{}
+
+	if _, err := solver.RecaptchaV2(budgetTask); err != nil {
+		t.Fatal(err)
+	}
+
+	// the reported cost replaces the estimate
+	if spent := solver.Budget.Spent("", time.Hour); spent != 0.002 {
+		t.Errorf("spent %v, want 0.002", spent)
+	}
+
+	if _, err := solver.RecaptchaV2(budgetTask); !errors.Is(err, captchago.ErrUnsolvable) {
+		t.Fatalf("got %v, want ErrUnsolvable", err)
+	}
+
+	// failed tasks cost nothing
+	if spent := solver.Budget.Spent("", time.Hour); spent != 0.002 {
+		t.Errorf("spent %v after a failed task, want 0.002", spent)
+	}
+
+	if tasks := solver.Budget.Tasks("", time.Hour); tasks != 2 {
+		t.Errorf("%d tasks, want 2", tasks)
+	}
+}
+
+func TestBudgetRefusedCreation(t *testing.T) {
+	srv := newServer(t)
+	srv.SetDefault(captchagotest.FailCreate("ERROR_ZERO_BALANCE"))
+
+	for _, service := range []captchago.SolveService{captchago.AntiCaptcha, captchago.TwoCaptcha, captchago.CapSolver} {
+		solver := srv.Solver(service)
+		solver.Budget = &captchago.Budget{}
+
+		var err error
+		if service == captchago.CapSolver {
+			_, err = solver.Kasada(captchago.KasadaOptions{PageURL: "https://example.com", Proxy: testProxy})
+		} else {
+			_, err = solver.RecaptchaV2(budgetTask)
+		}
+
+		if !errors.Is(err, captchago.ErrZeroBalance) {
+			t.Fatalf("%s: got %v, want ErrZeroBalance", service, err)
+		}
+
+		// tasks that weren't created aren't counted
+		if tasks := solver.Budget.Tasks("", time.Hour); tasks != 0 {
+			t.Errorf("%s: %d tasks charged, want 0", service, tasks)
+		}
+	}
+}
+
+func TestBudgetLabels(t *testing.T) {
+	srv := newServer(t)
+
+	solver := srv.Solver(captchago.AntiCaptcha)
+	solver.Budget = &captchago.Budget{
+		Labels: map[string]captchago.BudgetLimits{"signup": {MaxTasks: 1}},
+	}
+
+	signup := captchago.WithBudgetLabel(context.Background(), "signup")
+
+	if _, err := solver.RecaptchaV2Context(signup, budgetTask); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := solver.RecaptchaV2Context(signup, budgetTask); !errors.Is(err, captchago.ErrBudgetExceeded) {
+		t.Fatalf("got %v, want ErrBudgetExceeded", err)
+	}
+
+	// other labels aren't limited
+	if _, err := solver.RecaptchaV2(budgetTask); err != nil {
+		t.Fatal(err)
+	}
+
+	if tasks := solver.Budget.Tasks("signup", time.Hour); tasks != 1 {
+		t.Errorf("%d signup tasks, want 1", tasks)
+	}
+}
@@ -0,0 +1,339 @@
+package captchagotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/median/captchago"
+)
+
+// ErrUnmatched is returned by a Replayer for a request that isn't in the cassette
+var ErrUnmatched = errors.New("request not in cassette")
+
+// scrubbed replaces api keys and proxy credentials in cassettes
+const scrubbed = "REDACTED"
+
+// secretFields are the request fields holding an api key or proxy credentials, in json bodies, forms and queries
+var secretFields = map[string]bool{
+	"clientKey":     true,
+	"key":           true,
+	"proxyLogin":    true,
+	"proxyPassword": true,
+}
+
+// NewRecorder returns a transport that sends requests with next, http.DefaultTransport if nil,
+// and records every exchange so it can be saved as a cassette with Save
+func NewRecorder(next http.RoundTripper) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &Recorder{next: next}
+}
+
+// Client returns an http client using the recorder, to be set as Solver.HTTPClient
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded, err := newCassetteRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, Interaction{
+		Request: recorded,
+		Response: CassetteResponse{
+			Status:      resp.StatusCode,
+			ContentType: resp.Header.Get("Content-Type"),
+			Body:        string(body),
+		},
+	})
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// Save writes everything recorded so far to a cassette file
+func (r *Recorder) Save(path string) error {
+	var buf bytes.Buffer
+
+	// keep the queries readable, they're escaped as \u0026 otherwise
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	r.mu.Lock()
+	err := encoder.Encode(Cassette{Interactions: r.interactions})
+	r.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// LoadCassette reads a cassette saved by a Recorder and returns a transport that replays it
+func LoadCassette(path string) (*Replayer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, fmt.Errorf("cassette %s: %w", path, err)
+	}
+
+	p := &Replayer{queues: map[string][]CassetteResponse{}}
+	for _, i := range cassette.Interactions {
+		key := i.Request.key()
+		p.queues[key] = append(p.queues[key], i.Response)
+	}
+
+	return p, nil
+}
+
+// Solver returns a solver for the service that is answered from the cassette.
+// UpdateDelay and the retry delays are zero, so replays don't wait on anything,
+// and requests missing from the cassette fail the solve straight away instead of being retried
+func (p *Replayer) Solver(service captchago.SolveService) *captchago.Solver {
+	solver, err := captchago.New(service, scrubbed)
+	if err != nil {
+		panic(err)
+	}
+
+	policy := captchago.DefaultRetryPolicy
+	policy.BaseDelay = 0
+	policy.Jitter = 0
+	policy.ShouldRetry = func(err error) bool {
+		return !errors.Is(err, ErrUnmatched) && captchago.IsRetryable(err)
+	}
+
+	solver.HTTPClient = &http.Client{Transport: p}
+	solver.UpdateDelay = 0
+	solver.RetryPolicy = &policy
+
+	return solver
+}
+
+// RoundTrip answers req with the next response recorded for the same request.
+// Identical requests, like polls of the same task, get their responses in the order they were recorded
+func (p *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded, err := newCassetteRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	key := recorded.key()
+
+	p.mu.Lock()
+	queue := p.queues[key]
+	if len(queue) == 0 {
+		p.unmatched = append(p.unmatched, recorded)
+		p.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s %s %s", ErrUnmatched, recorded.Method, recorded.URL, recorded.Body)
+	}
+	resp := queue[0]
+	p.queues[key] = queue[1:]
+	p.mu.Unlock()
+
+	header := http.Header{}
+	if resp.ContentType != "" {
+		header.Set("Content-Type", resp.ContentType)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", resp.Status, http.StatusText(resp.Status)),
+		StatusCode:    resp.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(resp.Body)),
+		ContentLength: int64(len(resp.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Unmatched returns the requests that weren't in the cassette
+func (p *Replayer) Unmatched() []CassetteRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	unmatched := make([]CassetteRequest, len(p.unmatched))
+	copy(unmatched, p.unmatched)
+	return unmatched
+}
+
+// Remaining returns the number of recorded responses that weren't replayed
+func (p *Replayer) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := 0
+	for _, queue := range p.queues {
+		n += len(queue)
+	}
+	return n
+}
+
+// newCassetteRequest returns req with its secrets scrubbed and its parameters in a stable order.
+// The body of req is read and replaced so it can still be sent
+func newCassetteRequest(req *http.Request) (CassetteRequest, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return CassetteRequest{}, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	u := *req.URL
+	u.User = nil
+	u.RawQuery = scrubValues(u.Query()).Encode()
+
+	recorded := CassetteRequest{
+		Method: req.Method,
+		URL:    u.String(),
+	}
+
+	switch {
+	case len(body) == 0:
+	case strings.Contains(req.Header.Get("Content-Type"), "json"):
+		var data interface{}
+		if err := json.Unmarshal(body, &data); err != nil {
+			return recorded, err
+		}
+
+		// maps are encoded with sorted keys
+		scrubbedBody, err := json.Marshal(scrubJSON(data))
+		if err != nil {
+			return recorded, err
+		}
+		recorded.Body = string(scrubbedBody)
+	case strings.Contains(req.Header.Get("Content-Type"), "x-www-form-urlencoded"):
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return recorded, err
+		}
+		recorded.Body = scrubValues(values).Encode()
+	default:
+		recorded.Body = string(body)
+	}
+
+	return recorded, nil
+}
+
+func scrubValues(values url.Values) url.Values {
+	for k, v := range values {
+		for i := range v {
+			v[i] = scrubValue(k, v[i])
+		}
+	}
+	return values
+}
+
+func scrubJSON(data interface{}) interface{} {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for k, field := range v {
+			if s, ok := field.(string); ok {
+				v[k] = scrubValue(k, s)
+			} else {
+				v[k] = scrubJSON(field)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = scrubJSON(v[i])
+		}
+	}
+	return data
+}
+
+// scrubValue returns value without secrets, proxies like "user:pass@host:port" keep their address
+func scrubValue(key, value string) string {
+	if secretFields[key] && value != "" {
+		return scrubbed
+	}
+
+	if at := strings.LastIndex(value, "@"); at != -1 && strings.Contains(value[:at], ":") && !strings.Contains(value[:at], " ") {
+		prefix := ""
+		if i := strings.Index(value, "://"); i != -1 && i < at {
+			prefix = value[:i+3]
+		}
+		return prefix + scrubbed + ":" + scrubbed + value[at:]
+	}
+
+	return value
+}
+
+// key identifies a request when replaying
+func (r CassetteRequest) key() string {
+	return r.Method + " " + r.URL + "\n" + r.Body
+}
+
+// Recorder is an http.RoundTripper that records exchanges with a service, see NewRecorder
+type Recorder struct {
+	next http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+// Replayer is an http.RoundTripper that answers requests from a cassette, see LoadCassette
+type Replayer struct {
+	mu        sync.Mutex
+	queues    map[string][]CassetteResponse
+	unmatched []CassetteRequest
+}
+
+// Cassette is the file format of recorded exchanges
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+// CassetteRequest is a recorded request, api keys and proxy credentials are replaced with "REDACTED"
+type CassetteRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+type CassetteResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        string `json:"body"`
+}
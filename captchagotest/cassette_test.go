@@ -0,0 +1,96 @@
+package captchagotest_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/median/captchago"
+	"github.com/median/captchago/captchagotest"
+)
+
+func TestRecordReplay(t *testing.T) {
+	proxy := captchago.NewProxy(captchago.ProxyTypeHTTP, "127.0.0.1", 8080, &captchago.ProxyLogon{Username: "proxy-user", Password: "proxy-secret"})
+	task := captchago.HCaptchaOptions{PageURL: "https://example.com", SiteKey: "site-key", Proxy: proxy}
+
+	for _, service := range []captchago.SolveService{captchago.AntiCaptcha, captchago.TwoCaptcha} {
+		t.Run(service, func(t *testing.T) {
+			srv := newServer(t)
+			srv.Script(captchago.CaptchaHCaptcha, captchagotest.SolveAfter(2))
+
+			recorder := captchagotest.NewRecorder(nil)
+
+			solver := srv.Solver(service)
+			solver.HTTPClient = recorder.Client()
+
+			recorded, err := solver.HCaptcha(task)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join(t.TempDir(), "cassette.json")
+			if err := recorder.Save(path); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, secret := range []string{captchagotest.APIKey, "proxy-user", "proxy-secret"} {
+				if strings.Contains(string(data), secret) {
+					t.Errorf("cassette contains %q", secret)
+				}
+			}
+
+			// the server isn't needed anymore
+			srv.Close()
+
+			replayer, err := captchagotest.LoadCassette(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the cassette was recorded against the server's url
+			replay := replayer.Solver(service)
+			replay.ForcedDomain = srv.URL
+
+			replayed, err := replay.HCaptcha(task)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if replayed.Text != recorded.Text || replayed.TaskId != recorded.TaskId {
+				t.Errorf("replayed %+v, recorded %+v", replayed, recorded)
+			}
+
+			if n := replayer.Remaining(); n != 0 {
+				t.Errorf("%d responses weren't replayed", n)
+			}
+
+			// there's nothing left to answer with
+			_, err = replay.HCaptcha(task)
+			if !errors.Is(err, captchagotest.ErrUnmatched) {
+				t.Fatalf("got %v, want ErrUnmatched", err)
+			}
+
+			if len(replayer.Unmatched()) != 1 {
+				t.Errorf("unmatched %v, want the task creation", replayer.Unmatched())
+			}
+		})
+	}
+}
+
+func TestLoadCassetteInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := captchagotest.LoadCassette(path); err == nil {
+		t.Fatal("invalid cassette loaded")
+	}
+}
@@ -0,0 +1,182 @@
+package captchagotest
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	FaultLatency       Fault = "latency"
+	FaultServerError   Fault = "server_error"
+	FaultTruncatedJSON Fault = "truncated_json"
+	FaultStringTaskID  Fault = "string_task_id"
+	FaultStuckTask     Fault = "stuck_task"
+	FaultRateLimit     Fault = "rate_limit"
+)
+
+// Chaos makes the server misbehave like a real service sometimes does, see Server.SetChaos.
+// Every fault has the probability of it happening on a request, between 0 and 1
+type Chaos struct {
+	// Seed makes the faults reproducible, the same requests sent in the same order get the same faults
+	Seed int64
+
+	// Latency delays the response by up to MaxLatency
+	Latency float64
+
+	// MaxLatency is the longest delay added by Latency, defaults to 100ms
+	MaxLatency time.Duration
+
+	// ServerError answers with a 5xx status and an html error page
+	ServerError float64
+
+	// TruncatedJSON cuts json responses short
+	TruncatedJSON float64
+
+	// StringTaskID returns the task id of created tasks as a string, anti-captcha family only
+	StringTaskID float64
+
+	// StuckTask makes new tasks stay processing forever
+	StuckTask float64
+
+	// RateLimit answers with a rate limit error
+	RateLimit float64
+}
+
+type Fault = string
+
+// SetChaos starts injecting faults into the responses, a zero Chaos stops it
+func (s *Server) SetChaos(c Chaos) {
+	if c.MaxLatency <= 0 {
+		c.MaxLatency = time.Millisecond * 100
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.chaos = c
+	s.rand = rand.New(rand.NewSource(c.Seed))
+}
+
+// Faults returns how many times every fault was injected
+func (s *Server) Faults() map[Fault]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	faults := make(map[Fault]int, len(s.faults))
+	for k, v := range s.faults {
+		faults[k] = v
+	}
+	return faults
+}
+
+func (s *Server) chaosConfig() Chaos {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.chaos
+}
+
+// inject reports whether a fault with probability p happens, counting it if it does
+func (s *Server) inject(fault Fault, p float64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.injectLocked(fault, p)
+}
+
+// injectLocked is inject with s.mu held
+func (s *Server) injectLocked(fault Fault, p float64) bool {
+	if p <= 0 || s.rand == nil || s.rand.Float64() >= p {
+		return false
+	}
+
+	s.faults[fault]++
+	return true
+}
+
+// withChaos wraps the handlers of the server with the faults that don't depend on the endpoint
+func (s *Server) withChaos(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		c := s.chaos
+		var delay time.Duration
+		if s.injectLocked(FaultLatency, c.Latency) {
+			delay = time.Duration(s.rand.Int63n(int64(c.MaxLatency)))
+		}
+		serverError := s.injectLocked(FaultServerError, c.ServerError)
+		rateLimit := !serverError && s.injectLocked(FaultRateLimit, c.RateLimit)
+		truncate := !serverError && !rateLimit && s.injectLocked(FaultTruncatedJSON, c.TruncatedJSON)
+		status := http.StatusInternalServerError
+		if serverError {
+			status = []int{500, 502, 503, 504}[s.rand.Intn(4)]
+		}
+		s.mu.Unlock()
+
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		switch {
+		case serverError:
+			s.record(r, nil, "", "")
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(status)
+			fmt.Fprintf(w, "<html><body><h1>%d %s</h1></body></html>", status, http.StatusText(status))
+		case rateLimit:
+			s.record(r, nil, "", "")
+			if strings.HasSuffix(r.URL.Path, ".php") {
+				fmt.Fprint(w, "ERROR_TOO_MUCH_REQUESTS")
+			} else {
+				writeError(w, "ERROR_TOO_MUCH_REQUESTS")
+			}
+		case truncate:
+			buf := &bufferedWriter{header: http.Header{}, status: http.StatusOK}
+			next.ServeHTTP(buf, r)
+
+			// 2captcha answers some tasks with json after "OK|"
+			body := bytes.TrimSpace(buf.body.Bytes())
+			if strings.Contains(buf.header.Get("Content-Type"), "json") || bytes.HasPrefix(body, []byte("OK|{")) {
+				body = body[:len(body)/2]
+			} else {
+				s.mu.Lock()
+				s.faults[FaultTruncatedJSON]--
+				s.mu.Unlock()
+			}
+
+			for k, v := range buf.header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(buf.status)
+			_, _ = w.Write(body)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// bufferedWriter holds a response back so it can be changed before it's sent
+type bufferedWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedWriter) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+func (b *bufferedWriter) WriteHeader(status int) {
+	b.status = status
+}
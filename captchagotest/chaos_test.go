@@ -0,0 +1,134 @@
+package captchagotest_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/median/captchago"
+	"github.com/median/captchago/captchagotest"
+)
+
+func TestChaosFaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		service captchago.SolveService
+		chaos   captchagotest.Chaos
+		check   func(t *testing.T, sol *captchago.Solution, err error)
+	}{
+		{"rate limit", captchago.AntiCaptcha, captchagotest.Chaos{RateLimit: 1}, func(t *testing.T, sol *captchago.Solution, err error) {
+			if !errors.Is(err, captchago.ErrRateLimited) {
+				t.Errorf("got %v, want ErrRateLimited", err)
+			}
+		}},
+		{"truncated json", captchago.AntiCaptcha, captchagotest.Chaos{TruncatedJSON: 1}, func(t *testing.T, sol *captchago.Solution, err error) {
+			if err == nil {
+				t.Error("truncated response was accepted")
+			}
+		}},
+		{"string task id", captchago.AntiCaptcha, captchagotest.Chaos{StringTaskID: 1}, func(t *testing.T, sol *captchago.Solution, err error) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := sol.TaskId.(string); !ok {
+				t.Errorf("task id %#v, want a string", sol.TaskId)
+			}
+		}},
+		{"stuck task", captchago.AntiCaptcha, captchagotest.Chaos{StuckTask: 1}, func(t *testing.T, sol *captchago.Solution, err error) {
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("got %v, want context.DeadlineExceeded", err)
+			}
+		}},
+		{"latency", captchago.AntiCaptcha, captchagotest.Chaos{Latency: 1, MaxLatency: time.Millisecond * 5}, func(t *testing.T, sol *captchago.Solution, err error) {
+			if err != nil {
+				t.Error(err)
+			}
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := newServer(t)
+			srv.SetChaos(test.chaos)
+
+			solver := srv.Solver(test.service)
+			solver.RetryPolicy.CreateAttempts = 1
+			solver.RetryPolicy.MaxPollFailures = 0
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+			defer cancel()
+
+			sol, err := solver.HCaptchaContext(ctx, hcaptcha)
+			test.check(t, sol, err)
+
+			if len(srv.Faults()) == 0 {
+				t.Error("no fault was counted")
+			}
+		})
+	}
+}
+
+func TestChaosServerErrorRecovers(t *testing.T) {
+	for _, service := range []captchago.SolveService{captchago.AntiCaptcha, captchago.TwoCaptcha} {
+		t.Run(service, func(t *testing.T) {
+			srv := newServer(t)
+			srv.SetChaos(captchagotest.Chaos{Seed: 1, ServerError: 0.3})
+
+			// a 5xx page may hide a created task, so creation is only retried when that's allowed
+			solver := srv.Solver(service)
+			solver.RetryPolicy.CreateAttempts = 5
+			solver.RetryPolicy.RetryAmbiguous = true
+
+			for i := 0; i < 5; i++ {
+				if _, err := solver.HCaptcha(hcaptcha); err != nil {
+					t.Fatalf("solve %d didn't recover: %v", i, err)
+				}
+			}
+
+			if srv.Faults()[captchagotest.FaultServerError] == 0 {
+				t.Error("no server error was injected")
+			}
+		})
+	}
+}
+
+func TestChaosSeed(t *testing.T) {
+	run := func() map[captchagotest.Fault]int {
+		srv := newServer(t)
+		srv.SetChaos(captchagotest.Chaos{
+			Seed:          42,
+			ServerError:   0.1,
+			TruncatedJSON: 0.1,
+			StringTaskID:  0.2,
+			RateLimit:     0.1,
+		})
+
+		solver := srv.Solver(captchago.AntiCaptcha)
+		for i := 0; i < 20; i++ {
+			_, _ = solver.HCaptcha(hcaptcha)
+		}
+
+		return srv.Faults()
+	}
+
+	first, second := run(), run()
+	if len(first) == 0 {
+		t.Fatal("no faults were injected")
+	}
+
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("the same seed injected %v and %v", first, second)
+	}
+}
+
+func TestChaosOff(t *testing.T) {
+	srv := newServer(t)
+	srv.SetChaos(captchagotest.Chaos{ServerError: 1})
+	srv.SetChaos(captchagotest.Chaos{})
+
+	if _, err := srv.Solver(captchago.AntiCaptcha).HCaptcha(hcaptcha); err != nil {
+		t.Fatal(err)
+	}
+}
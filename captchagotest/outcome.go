@@ -0,0 +1,170 @@
+package captchagotest
+
+import (
+	"strings"
+
+	"github.com/median/captchago"
+)
+
+// Token is the solution text of every default solution
+const Token = "captchagotest-token"
+
+// Outcome scripts how the server answers one task
+type Outcome struct {
+	// Polls is the number of result requests answered with "processing" before the task finishes
+	Polls int
+
+	// Solution is the solution of the task, DefaultSolution of its captcha type if nil.
+	// 2captcha answers with its "text" or "cookie" field, or the whole solution as json
+	// if it has neither or the task is answered with json (geetest and amazon waf)
+	Solution map[string]interface{}
+
+	// Cost is the cost reported with the solution, anti-captcha family only
+	Cost string
+
+	// CreateError is an error code like "ERROR_ZERO_BALANCE" returned instead of creating the task
+	CreateError string
+
+	// Error is an error code like "ERROR_CAPTCHA_UNSOLVABLE" returned instead of the solution
+	Error string
+
+	// Body is sent verbatim instead of the solution, to test malformed responses
+	Body string
+
+	// Immediate returns the solution with the createTask response, like capsolver does for some tasks.
+	// Ignored by 2captcha
+	Immediate bool
+}
+
+// SolveAfter returns an outcome that solves the task after polls result requests
+func SolveAfter(polls int) Outcome {
+	return Outcome{Polls: polls}
+}
+
+// FailCreate returns an outcome that fails creating the task with an error code
+func FailCreate(code string) Outcome {
+	return Outcome{CreateError: code}
+}
+
+// FailWith returns an outcome that fails the task with an error code after polls result requests
+func FailWith(polls int, code string) Outcome {
+	return Outcome{Polls: polls, Error: code}
+}
+
+// Malformed returns an outcome that answers the result request with body
+func Malformed(body string) Outcome {
+	return Outcome{Body: body}
+}
+
+// DefaultSolution returns a solution the solver accepts for the captcha type
+func DefaultSolution(captchaType captchago.CaptchaType) map[string]interface{} {
+	switch captchaType {
+	case captchago.CaptchaImage:
+		return map[string]interface{}{"text": Token}
+	case captchago.CaptchaGeeTest:
+		return map[string]interface{}{
+			"challenge":         Token,
+			"validate":          Token,
+			"seccode":           Token + "|jordan",
+			"geetest_challenge": Token,
+			"geetest_validate":  Token,
+			"geetest_seccode":   Token + "|jordan",
+		}
+	case captchago.CaptchaGeeTestV4:
+		return map[string]interface{}{
+			"captcha_id":     "captchagotest",
+			"lot_number":     Token,
+			"pass_token":     Token,
+			"gen_time":       "1700000000",
+			"captcha_output": Token,
+		}
+	case captchago.CaptchaAmazonWAF:
+		return map[string]interface{}{"cookie": Token}
+	case captchago.CaptchaDataDome:
+		return map[string]interface{}{"cookie": "datadome=" + Token + "; Path=/; Secure"}
+	case captchago.CaptchaAkamai:
+		return map[string]interface{}{
+			"sensor_data": []interface{}{Token},
+			"cookies":     map[string]interface{}{"_abck": Token},
+		}
+	case captchago.CaptchaKasada:
+		return map[string]interface{}{
+			"x-kpsdk-cd": Token,
+			"x-kpsdk-ct": Token,
+			"user-agent": "captchagotest",
+		}
+	}
+
+	return map[string]interface{}{
+		"gRecaptchaResponse": Token,
+		"token":              Token,
+		"text":               Token,
+	}
+}
+
+// antiCaptchaType returns the captcha type of an anti-captcha family task
+func antiCaptchaType(task map[string]interface{}) captchago.CaptchaType {
+	name, _ := task["type"].(string)
+
+	prefixes := []struct {
+		prefix      string
+		captchaType captchago.CaptchaType
+	}{
+		{"RecaptchaV2", captchago.CaptchaRecaptchaV2},
+		{"RecaptchaV3", captchago.CaptchaRecaptchaV3},
+		{"HCaptcha", captchago.CaptchaHCaptcha},
+		{"FunCaptcha", captchago.CaptchaFunCaptcha},
+		{"ImageToText", captchago.CaptchaImage},
+		{"AntiAwsWaf", captchago.CaptchaAmazonWAF},
+		{"Amazon", captchago.CaptchaAmazonWAF},
+		{"DataDome", captchago.CaptchaDataDome},
+		{"AntiAkamai", captchago.CaptchaAkamai},
+		{"AntiKasada", captchago.CaptchaKasada},
+		{"AntiCloudflare", captchago.CaptchaCloudflare},
+		{"Turnstile", captchago.CaptchaCloudflare},
+	}
+
+	if strings.HasPrefix(name, "GeeTest") {
+		if version, _ := task["version"].(float64); version == 4 {
+			return captchago.CaptchaGeeTestV4
+		}
+		return captchago.CaptchaGeeTest
+	}
+
+	for _, p := range prefixes {
+		if strings.HasPrefix(name, p.prefix) {
+			return p.captchaType
+		}
+	}
+
+	return ""
+}
+
+// twoCaptchaType returns the captcha type of a 2captcha in.php request
+func twoCaptchaType(method, version string) captchago.CaptchaType {
+	switch method {
+	case "userrecaptcha":
+		if version == "v3" {
+			return captchago.CaptchaRecaptchaV3
+		}
+		return captchago.CaptchaRecaptchaV2
+	case "hcaptcha":
+		return captchago.CaptchaHCaptcha
+	case "funcaptcha":
+		return captchago.CaptchaFunCaptcha
+	case "turnstile":
+		return captchago.CaptchaCloudflare
+	case "geetest":
+		return captchago.CaptchaGeeTest
+	case "geetest_v4":
+		return captchago.CaptchaGeeTestV4
+	case "amazon_waf":
+		return captchago.CaptchaAmazonWAF
+	case "datadome":
+		return captchago.CaptchaDataDome
+	case "base64", "post":
+		return captchago.CaptchaImage
+	}
+
+	return ""
+}
@@ -0,0 +1,525 @@
+// Package captchagotest runs a fake captcha service for tests. It speaks the protocols of the
+// anti-captcha family and 2captcha, so code using captchago can be tested without api keys or money.
+// Exchanges with a real service can also be recorded once and replayed, see NewRecorder and LoadCassette.
+package captchagotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/median/captchago"
+)
+
+// APIKey is the only api key the server accepts
+const APIKey = "captchagotest-key"
+
+// NewServer starts a fake service, it must be closed with Close.
+// Tasks are solved on the first result request unless scripted otherwise, see Script
+func NewServer() *Server {
+	s := &Server{
+		balance: 10,
+		scripts: map[string][]Outcome{},
+		tasks:   map[int]*task{},
+		nextId:  1,
+		faults:  map[Fault]int{},
+	}
+
+	mux := http.NewServeMux()
+
+	// anti-captcha family
+	mux.HandleFunc("/createTask", s.createTask)
+	mux.HandleFunc("/getTaskResult", s.getTaskResult)
+	mux.HandleFunc("/getBalance", s.getBalance)
+	mux.HandleFunc("/kasada/invoke", s.kasada)
+	for _, path := range []string{"/reportCorrectRecaptcha", "/reportIncorrectRecaptcha", "/reportIncorrectHcaptcha", "/reportIncorrectImageCaptcha", "/feedbackTask"} {
+		mux.HandleFunc(path, s.report)
+	}
+
+	// 2captcha
+	mux.HandleFunc("/in.php", s.in)
+	mux.HandleFunc("/res.php", s.res)
+
+	s.Server = httptest.NewServer(s.withChaos(mux))
+	return s
+}
+
+// Solver returns a solver for the service that sends every request to the server.
+// Delays are shortened so tests run fast
+func (s *Server) Solver(service captchago.SolveService) *captchago.Solver {
+	solver, err := captchago.New(service, APIKey)
+	if err != nil {
+		panic(err)
+	}
+
+	policy := captchago.DefaultRetryPolicy
+	policy.BaseDelay = time.Millisecond
+	policy.MaxDelay = time.Millisecond * 10
+
+	solver.ForcedDomain = s.URL
+	solver.UpdateDelay = time.Millisecond
+	solver.RetryPolicy = &policy
+
+	return solver
+}
+
+// Script queues outcomes for the next tasks of a kind. key is either a captcha type like captchago.CaptchaHCaptcha,
+// or a task type as sent to the service like "HCaptchaTaskProxyless" or "hcaptcha", which is checked first.
+// Once the queue is empty tasks use the default outcome
+func (s *Server) Script(key string, outcomes ...Outcome) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scripts[key] = append(s.scripts[key], outcomes...)
+}
+
+// SetDefault sets the outcome of tasks that weren't scripted
+func (s *Server) SetDefault(o Outcome) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fallback = o
+}
+
+// SetBalance sets the balance returned by getBalance, it starts at 10
+func (s *Server) SetBalance(balance float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.balance = balance
+}
+
+// Requests returns every request the server received, in order
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	requests := make([]Request, len(s.requests))
+	copy(requests, s.requests)
+	return requests
+}
+
+// Tasks returns the requests that created a task, in order
+func (s *Server) Tasks() []Request {
+	var tasks []Request
+	for _, r := range s.Requests() {
+		if r.Type != "" || r.TaskType != "" {
+			tasks = append(tasks, r)
+		}
+	}
+	return tasks
+}
+
+func (s *Server) createTask(w http.ResponseWriter, r *http.Request) {
+	params, ok := s.decodeJSON(w, r)
+	if !ok {
+		return
+	}
+
+	taskData, _ := params["task"].(map[string]interface{})
+	taskType, _ := taskData["type"].(string)
+	captchaType := antiCaptchaType(taskData)
+
+	s.record(r, params, taskType, captchaType)
+
+	if params["clientKey"] != APIKey {
+		writeError(w, "ERROR_KEY_DOES_NOT_EXIST")
+		return
+	}
+
+	t := s.newTask(taskType, captchaType)
+	if t.outcome.CreateError != "" {
+		writeError(w, t.outcome.CreateError)
+		return
+	}
+
+	if t.outcome.Immediate && t.outcome.Polls == 0 && t.outcome.Body == "" && s.poll(t) {
+		resp := t.result()
+		resp["taskId"] = t.id
+		writeJSON(w, resp)
+		return
+	}
+
+	var taskId interface{} = t.id
+	if s.inject(FaultStringTaskID, s.chaosConfig().StringTaskID) {
+		taskId = strconv.Itoa(t.id)
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"errorId": 0,
+		"taskId":  taskId,
+	})
+}
+
+func (s *Server) getTaskResult(w http.ResponseWriter, r *http.Request) {
+	params, ok := s.decodeJSON(w, r)
+	if !ok {
+		return
+	}
+
+	s.record(r, params, "", "")
+
+	if params["clientKey"] != APIKey {
+		writeError(w, "ERROR_KEY_DOES_NOT_EXIST")
+		return
+	}
+
+	t := s.task(fmt.Sprint(params["taskId"]))
+	if t == nil {
+		writeError(w, "ERROR_NO_SUCH_CAPCHA_ID")
+		return
+	}
+
+	switch {
+	case !s.poll(t):
+		writeJSON(w, map[string]interface{}{"errorId": 0, "status": "processing"})
+	case t.outcome.Body != "":
+		fmt.Fprint(w, t.outcome.Body)
+	default:
+		writeJSON(w, t.result())
+	}
+}
+
+func (s *Server) getBalance(w http.ResponseWriter, r *http.Request) {
+	params, ok := s.decodeJSON(w, r)
+	if !ok {
+		return
+	}
+
+	s.record(r, params, "", "")
+
+	if params["clientKey"] != APIKey {
+		writeError(w, "ERROR_KEY_DOES_NOT_EXIST")
+		return
+	}
+
+	s.mu.Lock()
+	balance := s.balance
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]interface{}{
+		"errorId": 0,
+		"balance": balance,
+	})
+}
+
+// kasada answers straight away, polls are ignored
+func (s *Server) kasada(w http.ResponseWriter, r *http.Request) {
+	params, ok := s.decodeJSON(w, r)
+	if !ok {
+		return
+	}
+
+	taskData, _ := params["task"].(map[string]interface{})
+	taskType, _ := taskData["type"].(string)
+
+	s.record(r, params, taskType, captchago.CaptchaKasada)
+
+	if params["clientKey"] != APIKey {
+		writeError(w, "ERROR_KEY_DOES_NOT_EXIST")
+		return
+	}
+
+	t := s.newTask(taskType, captchago.CaptchaKasada)
+
+	switch {
+	case t.outcome.CreateError != "":
+		writeError(w, t.outcome.CreateError)
+	case t.outcome.Body != "":
+		fmt.Fprint(w, t.outcome.Body)
+	case t.outcome.Error != "":
+		writeError(w, t.outcome.Error)
+	default:
+		writeJSON(w, map[string]interface{}{
+			"errorId":  0,
+			"status":   "ready",
+			"solution": t.solution(),
+		})
+	}
+}
+
+func (s *Server) report(w http.ResponseWriter, r *http.Request) {
+	params, ok := s.decodeJSON(w, r)
+	if !ok {
+		return
+	}
+
+	s.record(r, params, "", "")
+
+	if params["clientKey"] != APIKey {
+		writeError(w, "ERROR_KEY_DOES_NOT_EXIST")
+		return
+	}
+
+	if s.task(fmt.Sprint(params["taskId"])) == nil {
+		writeError(w, "ERROR_NO_SUCH_CAPCHA_ID")
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"errorId": 0,
+		"status":  "success",
+	})
+}
+
+func (s *Server) in(w http.ResponseWriter, r *http.Request) {
+	params := formParams(r)
+
+	method, _ := params["method"].(string)
+	version, _ := params["version"].(string)
+	captchaType := twoCaptchaType(method, version)
+
+	s.record(r, params, method, captchaType)
+
+	if params["key"] != APIKey {
+		fmt.Fprint(w, "ERROR_WRONG_USER_KEY")
+		return
+	}
+
+	t := s.newTask(method, captchaType)
+	if t.outcome.CreateError != "" {
+		fmt.Fprint(w, t.outcome.CreateError)
+		return
+	}
+
+	fmt.Fprintf(w, "OK|%d", t.id)
+}
+
+func (s *Server) res(w http.ResponseWriter, r *http.Request) {
+	params := formParams(r)
+	s.record(r, params, "", "")
+
+	if params["key"] != APIKey {
+		fmt.Fprint(w, "ERROR_WRONG_USER_KEY")
+		return
+	}
+
+	switch params["action"] {
+	case "getbalance":
+		s.mu.Lock()
+		balance := s.balance
+		s.mu.Unlock()
+
+		fmt.Fprint(w, strconv.FormatFloat(balance, 'f', -1, 64))
+	case "get":
+		t := s.task(fmt.Sprint(params["id"]))
+		switch {
+		case t == nil:
+			fmt.Fprint(w, "ERROR_WRONG_CAPTCHA_ID")
+		case !s.poll(t):
+			fmt.Fprint(w, "CAPCHA_NOT_READY")
+		case t.outcome.Body != "":
+			fmt.Fprint(w, t.outcome.Body)
+		case t.outcome.Error != "":
+			fmt.Fprint(w, t.outcome.Error)
+		default:
+			fmt.Fprint(w, "OK|"+t.answer())
+		}
+	case "reportbad", "reportgood":
+		if s.task(fmt.Sprint(params["id"])) == nil {
+			fmt.Fprint(w, "ERROR_WRONG_CAPTCHA_ID")
+			return
+		}
+		fmt.Fprint(w, "OK_REPORT_RECORDED")
+	default:
+		fmt.Fprint(w, "ERROR_WRONG_ACTION")
+	}
+}
+
+// newTask creates a task with the next outcome scripted for it
+func (s *Server) newTask(taskType string, captchaType captchago.CaptchaType) *task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	outcome := s.fallback
+	for _, key := range []string{taskType, captchaType} {
+		if queue := s.scripts[key]; key != "" && len(queue) > 0 {
+			outcome = queue[0]
+			s.scripts[key] = queue[1:]
+			break
+		}
+	}
+
+	t := &task{
+		id:          s.nextId,
+		captchaType: captchaType,
+		outcome:     outcome,
+		stuck:       s.injectLocked(FaultStuckTask, s.chaos.StuckTask),
+	}
+
+	s.nextId++
+
+	// tasks that fail to be created can't be polled
+	if outcome.CreateError == "" {
+		s.tasks[t.id] = t
+	}
+
+	return t
+}
+
+func (s *Server) task(id string) *task {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.tasks[n]
+}
+
+// poll counts a result request for t, it returns true once the task is finished
+func (s *Server) poll(t *task) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t.polls++
+	return !t.stuck && t.polls > t.outcome.Polls
+}
+
+func (s *Server) record(r *http.Request, params map[string]interface{}, taskType string, captchaType captchago.CaptchaType) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, Request{
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		TaskType: taskType,
+		Type:     captchaType,
+		Params:   params,
+	})
+}
+
+// decodeJSON decodes the body of an anti-captcha family request, answering with an error if it isn't json
+func (s *Server) decodeJSON(w http.ResponseWriter, r *http.Request) (map[string]interface{}, bool) {
+	var params map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		s.record(r, nil, "", "")
+		writeError(w, "ERROR_BAD_REQUEST")
+		return nil, false
+	}
+
+	return params, true
+}
+
+// formParams returns the query and form values of a 2captcha request, in.php is sent as a form
+func formParams(r *http.Request) map[string]interface{} {
+	_ = r.ParseForm()
+
+	params := make(map[string]interface{}, len(r.Form))
+	for k := range r.Form {
+		params[k] = r.FormValue(k)
+	}
+
+	return params
+}
+
+func writeJSON(w http.ResponseWriter, v map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code string) {
+	writeJSON(w, errorBody(code))
+}
+
+func errorBody(code string) map[string]interface{} {
+	return map[string]interface{}{
+		"errorId":          1,
+		"errorCode":        code,
+		"errorDescription": code,
+	}
+}
+
+// Server is a fake captcha service, see NewServer
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	balance  float64
+	scripts  map[string][]Outcome
+	fallback Outcome
+	tasks    map[int]*task
+	nextId   int
+	requests []Request
+
+	chaos  Chaos
+	rand   *rand.Rand
+	faults map[Fault]int
+}
+
+// Request is a request received by the server
+type Request struct {
+	Method string
+	Path   string
+
+	// TaskType is the task type as sent for requests that create a task,
+	// the task "type" for the anti-captcha family and the "method" for 2captcha
+	TaskType string
+
+	// Type is the captcha type of requests that create a task, "" if it's unknown
+	Type captchago.CaptchaType
+
+	// Params is the json body for the anti-captcha family, or the query and form values for 2captcha
+	Params map[string]interface{}
+}
+
+type task struct {
+	id          int
+	captchaType captchago.CaptchaType
+	outcome     Outcome
+	polls       int
+	stuck       bool
+}
+
+func (t *task) solution() map[string]interface{} {
+	if t.outcome.Solution != nil {
+		return t.outcome.Solution
+	}
+	return DefaultSolution(t.captchaType)
+}
+
+// result returns the anti-captcha response of a finished task
+func (t *task) result() map[string]interface{} {
+	if t.outcome.Error != "" {
+		return errorBody(t.outcome.Error)
+	}
+
+	resp := map[string]interface{}{
+		"errorId":  0,
+		"status":   "ready",
+		"solution": t.solution(),
+		"ip":       "127.0.0.1",
+	}
+
+	if t.outcome.Cost != "" {
+		resp["cost"] = t.outcome.Cost
+	}
+
+	return resp
+}
+
+// answer returns the 2captcha answer of the task
+func (t *task) answer() string {
+	sol := t.solution()
+
+	// these are answered with a json object
+	switch t.captchaType {
+	case captchago.CaptchaGeeTest, captchago.CaptchaGeeTestV4, captchago.CaptchaAmazonWAF:
+	default:
+		for _, key := range []string{"text", "cookie"} {
+			if text, ok := sol[key].(string); ok {
+				return text
+			}
+		}
+	}
+
+	data, _ := json.Marshal(sol)
+	return string(data)
+}
@@ -0,0 +1,131 @@
+package captchagotest_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/median/captchago"
+	"github.com/median/captchago/captchagotest"
+)
+
+func newServer(t *testing.T) *captchagotest.Server {
+	t.Helper()
+
+	srv := captchagotest.NewServer()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+var hcaptcha = captchago.HCaptchaOptions{PageURL: "https://example.com", SiteKey: "site-key"}
+
+func TestServerScript(t *testing.T) {
+	for _, service := range []captchago.SolveService{captchago.AntiCaptcha, captchago.TwoCaptcha} {
+		t.Run(service, func(t *testing.T) {
+			srv := newServer(t)
+			srv.Script(captchago.CaptchaHCaptcha, captchagotest.SolveAfter(3), captchagotest.Outcome{Solution: map[string]interface{}{"gRecaptchaResponse": "scripted", "text": "scripted"}})
+
+			solver := srv.Solver(service)
+
+			sol, err := solver.HCaptcha(hcaptcha)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sol.Text != captchagotest.Token {
+				t.Errorf("first solution %q", sol.Text)
+			}
+
+			polls := 0
+			for _, r := range srv.Requests() {
+				if r.Path == "/getTaskResult" || r.Path == "/res.php" {
+					polls++
+				}
+			}
+			if polls != 4 {
+				t.Errorf("%d result requests, want 4", polls)
+			}
+
+			sol, err = solver.HCaptcha(hcaptcha)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sol.Text != "scripted" {
+				t.Errorf("second solution %q, want the scripted one", sol.Text)
+			}
+
+			tasks := srv.Tasks()
+			if len(tasks) != 2 || tasks[0].Type != captchago.CaptchaHCaptcha {
+				t.Errorf("tasks %+v", tasks)
+			}
+		})
+	}
+}
+
+func TestServerScriptByTaskType(t *testing.T) {
+	srv := newServer(t)
+
+	// the task type is checked before the captcha type
+	srv.Script("HCaptchaTaskProxyless", captchagotest.FailCreate("ERROR_ZERO_BALANCE"))
+	srv.Script(captchago.CaptchaHCaptcha, captchagotest.FailCreate("ERROR_NO_SLOT_AVAILABLE"))
+
+	solver := srv.Solver(captchago.AntiCaptcha)
+	solver.RetryPolicy.CreateAttempts = 1
+
+	if _, err := solver.HCaptcha(hcaptcha); !errors.Is(err, captchago.ErrZeroBalance) {
+		t.Fatalf("got %v, want ErrZeroBalance", err)
+	}
+
+	if _, err := solver.HCaptcha(hcaptcha); !errors.Is(err, captchago.ErrNoSlotAvailable) {
+		t.Fatalf("got %v, want ErrNoSlotAvailable", err)
+	}
+
+	if _, err := solver.HCaptcha(hcaptcha); err != nil {
+		t.Fatalf("unscripted task failed: %v", err)
+	}
+}
+
+func TestServerImmediate(t *testing.T) {
+	srv := newServer(t)
+	srv.Script(captchago.CaptchaImage, captchagotest.Outcome{Immediate: true})
+
+	sol, err := srv.Solver(captchago.CapSolver).ImageToText(captchago.ImageCaptchaOptions{Image: []byte("image")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sol.Text != captchagotest.Token {
+		t.Errorf("solution %q", sol.Text)
+	}
+
+	for _, r := range srv.Requests() {
+		if r.Path == "/getTaskResult" {
+			t.Fatal("solution returned with the task was polled")
+		}
+	}
+}
+
+func TestServerWrongKey(t *testing.T) {
+	for _, service := range []captchago.SolveService{captchago.AntiCaptcha, captchago.TwoCaptcha} {
+		t.Run(service, func(t *testing.T) {
+			solver := newServer(t).Solver(service)
+			solver.ApiKey = "wrong-key"
+
+			if _, err := solver.GetBalance(); !errors.Is(err, captchago.ErrInvalidKey) {
+				t.Fatalf("got %v, want ErrInvalidKey", err)
+			}
+		})
+	}
+}
+
+func TestServerParams(t *testing.T) {
+	srv := newServer(t)
+
+	proxy := captchago.NewProxy(captchago.ProxyTypeHTTP, "127.0.0.1", 8080, nil)
+	if _, err := srv.Solver(captchago.TwoCaptcha).HCaptcha(captchago.HCaptchaOptions{PageURL: "https://example.com", SiteKey: "site-key", Proxy: proxy}); err != nil {
+		t.Fatal(err)
+	}
+
+	task := srv.Tasks()[0]
+	if task.Path != "/in.php" || task.TaskType != "hcaptcha" || task.Params["sitekey"] != "site-key" || task.Params["proxy"] == nil {
+		t.Errorf("task request %+v", task)
+	}
+}
@@ -1,69 +1,543 @@
 package captchago
 
 import (
+	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type solveMethods struct {
-	GetBalance  func() (float64, error)
-	RecaptchaV2 func(RecaptchaV2Options) (*Solution, error)
-	RecaptchaV3 func(RecaptchaV3Options) (*Solution, error)
-	HCaptcha    func(HCaptchaOptions) (*Solution, error)
-	FunCaptcha  func(FunCaptchaOptions) (*Solution, error)
-	Kasada      func(KasadaOptions) (*KasadaSolution, error)
-	Cloudflare  func(CloudflareOptions) (*Solution, error)
+	GetBalance  func(context.Context) (float64, error)
+	RecaptchaV2 func(context.Context, RecaptchaV2Options) (*Solution, error)
+	RecaptchaV3 func(context.Context, RecaptchaV3Options) (*Solution, error)
+	HCaptcha    func(context.Context, HCaptchaOptions) (*Solution, error)
+	FunCaptcha  func(context.Context, FunCaptchaOptions) (*Solution, error)
+	Kasada      func(context.Context, KasadaOptions) (*KasadaSolution, error)
+	Akamai      func(context.Context, AkamaiOptions) (*AkamaiSolution, error)
+	Cloudflare  func(context.Context, CloudflareOptions) (*Solution, error)
+	ImageToText func(context.Context, ImageCaptchaOptions) (*Solution, error)
+	GeeTest     func(context.Context, GeeTestOptions) (*GeeTestSolution, error)
+	GeeTestV4   func(context.Context, GeeTestV4Options) (*GeeTestV4Solution, error)
+	AmazonWAF   func(context.Context, AmazonWAFOptions) (*Solution, error)
+	DataDome    func(context.Context, DataDomeOptions) (*Solution, error)
+
+	// TaskResult requests the result of a task once, the solution is nil while it's processing
+	TaskResult func(ctx context.Context, taskId any) (*Solution, error)
+
+	// Report sends a correct or incorrect report for a solution
+	Report func(ctx context.Context, sol *Solution, correct bool) error
+
+	// domain returns the base url of the service, it keys the circuit breaker
+	domain func() string
 }
 
 // GetBalance returns the balance of the account
 func (s *Solver) GetBalance() (float64, error) {
+	return s.GetBalanceContext(context.Background())
+}
+
+// GetBalanceContext is GetBalance but stops once ctx is done
+func (s *Solver) GetBalanceContext(ctx context.Context) (float64, error) {
 	if s.methods.GetBalance == nil {
-		return 0, errors.New("service does not support getBalance")
+		return 0, s.unsupported("getBalance")
 	}
-	return s.methods.GetBalance()
+	return s.methods.GetBalance(ctx)
 }
 
 // RecaptchaV2 solves a recaptcha v2
 func (s *Solver) RecaptchaV2(o RecaptchaV2Options) (*Solution, error) {
+	return s.RecaptchaV2Context(context.Background(), o)
+}
+
+// RecaptchaV2Context is RecaptchaV2 but stops polling once ctx is done
+func (s *Solver) RecaptchaV2Context(ctx context.Context, o RecaptchaV2Options) (*Solution, error) {
 	if s.methods.RecaptchaV2 == nil {
-		return nil, errors.New("service does not support recaptchaV2")
+		return nil, s.unsupported("recaptchaV2")
 	}
-	return s.methods.RecaptchaV2(o)
+	ctx, done := s.begin(ctx, CaptchaRecaptchaV2)
+	sol, err := s.methods.RecaptchaV2(ctx, o)
+	done(sol, err)
+	return sol, err
 }
 
 func (s *Solver) RecaptchaV3(o RecaptchaV3Options) (*Solution, error) {
+	return s.RecaptchaV3Context(context.Background(), o)
+}
+
+func (s *Solver) RecaptchaV3Context(ctx context.Context, o RecaptchaV3Options) (*Solution, error) {
 	if s.methods.RecaptchaV3 == nil {
-		return nil, errors.New("service does not support recaptchaV3")
+		return nil, s.unsupported("recaptchaV3")
 	}
-	return s.methods.RecaptchaV3(o)
+	ctx, done := s.begin(ctx, CaptchaRecaptchaV3)
+	sol, err := s.methods.RecaptchaV3(ctx, o)
+	done(sol, err)
+	return sol, err
 }
 
 func (s *Solver) HCaptcha(o HCaptchaOptions) (*Solution, error) {
+	return s.HCaptchaContext(context.Background(), o)
+}
+
+func (s *Solver) HCaptchaContext(ctx context.Context, o HCaptchaOptions) (*Solution, error) {
 	if s.methods.HCaptcha == nil {
-		return nil, errors.New("service does not support hCaptcha")
+		return nil, s.unsupported("hCaptcha")
 	}
-	return s.methods.HCaptcha(o)
+	ctx, done := s.begin(ctx, CaptchaHCaptcha)
+	sol, err := s.methods.HCaptcha(ctx, o)
+	done(sol, err)
+	return sol, err
 }
 
 func (s *Solver) FunCaptcha(o FunCaptchaOptions) (*Solution, error) {
+	return s.FunCaptchaContext(context.Background(), o)
+}
+
+func (s *Solver) FunCaptchaContext(ctx context.Context, o FunCaptchaOptions) (*Solution, error) {
 	if s.methods.FunCaptcha == nil {
-		return nil, errors.New("service does not support funCaptcha")
+		return nil, s.unsupported("funCaptcha")
 	}
-	return s.methods.FunCaptcha(o)
+	ctx, done := s.begin(ctx, CaptchaFunCaptcha)
+	sol, err := s.methods.FunCaptcha(ctx, o)
+	done(sol, err)
+	return sol, err
 }
 
 func (s *Solver) Cloudflare(o CloudflareOptions) (*Solution, error) {
+	return s.CloudflareContext(context.Background(), o)
+}
+
+func (s *Solver) CloudflareContext(ctx context.Context, o CloudflareOptions) (*Solution, error) {
 	if s.methods.Cloudflare == nil {
-		return nil, errors.New("service does not support cloudflare")
+		return nil, s.unsupported("cloudflare")
 	}
-	return s.methods.Cloudflare(o)
+	ctx, done := s.begin(ctx, CaptchaCloudflare)
+	sol, err := s.methods.Cloudflare(ctx, o)
+	done(sol, err)
+	return sol, err
 }
 
 // Kasada is only supported with capsolver.com
 func (s *Solver) Kasada(o KasadaOptions) (*KasadaSolution, error) {
+	return s.KasadaContext(context.Background(), o)
+}
+
+// KasadaContext is Kasada but stops once ctx is done
+func (s *Solver) KasadaContext(ctx context.Context, o KasadaOptions) (*KasadaSolution, error) {
 	if s.methods.Kasada == nil {
-		return nil, errors.New("service does not support kasada")
+		return nil, s.unsupported("kasada")
+	}
+	ctx, done := s.begin(ctx, CaptchaKasada)
+	sol, err := s.methods.Kasada(ctx, o)
+	done(sol.base(), err)
+	return sol, err
+}
+
+// Akamai generates akamai bot manager sensor data, it is only supported with capsolver.com
+func (s *Solver) Akamai(o AkamaiOptions) (*AkamaiSolution, error) {
+	return s.AkamaiContext(context.Background(), o)
+}
+
+func (s *Solver) AkamaiContext(ctx context.Context, o AkamaiOptions) (*AkamaiSolution, error) {
+	if s.methods.Akamai == nil {
+		return nil, s.unsupported("akamai")
+	}
+	ctx, done := s.begin(ctx, CaptchaAkamai)
+	sol, err := s.methods.Akamai(ctx, o)
+	done(sol.base(), err)
+	return sol, err
+}
+
+// ImageToText solves a normal image captcha, the recognized text is returned in Solution.Text
+func (s *Solver) ImageToText(o ImageCaptchaOptions) (*Solution, error) {
+	return s.ImageToTextContext(context.Background(), o)
+}
+
+func (s *Solver) ImageToTextContext(ctx context.Context, o ImageCaptchaOptions) (*Solution, error) {
+	if s.methods.ImageToText == nil {
+		return nil, s.unsupported("imageToText")
+	}
+	ctx, done := s.begin(ctx, CaptchaImage)
+	sol, err := s.methods.ImageToText(ctx, o)
+	done(sol, err)
+	return sol, err
+}
+
+// GeeTest solves a geetest v3 captcha
+func (s *Solver) GeeTest(o GeeTestOptions) (*GeeTestSolution, error) {
+	return s.GeeTestContext(context.Background(), o)
+}
+
+func (s *Solver) GeeTestContext(ctx context.Context, o GeeTestOptions) (*GeeTestSolution, error) {
+	if s.methods.GeeTest == nil {
+		return nil, s.unsupported("geeTest")
+	}
+	ctx, done := s.begin(ctx, CaptchaGeeTest)
+	sol, err := s.methods.GeeTest(ctx, o)
+	done(sol.base(), err)
+	return sol, err
+}
+
+// GeeTestV4 solves a geetest v4 captcha
+func (s *Solver) GeeTestV4(o GeeTestV4Options) (*GeeTestV4Solution, error) {
+	return s.GeeTestV4Context(context.Background(), o)
+}
+
+func (s *Solver) GeeTestV4Context(ctx context.Context, o GeeTestV4Options) (*GeeTestV4Solution, error) {
+	if s.methods.GeeTestV4 == nil {
+		return nil, s.unsupported("geeTestV4")
+	}
+	ctx, done := s.begin(ctx, CaptchaGeeTestV4)
+	sol, err := s.methods.GeeTestV4(ctx, o)
+	done(sol.base(), err)
+	return sol, err
+}
+
+// AmazonWAF solves an aws waf captcha, the aws-waf-token cookie is returned in both Solution.Text and Solution.Cookies
+func (s *Solver) AmazonWAF(o AmazonWAFOptions) (*Solution, error) {
+	return s.AmazonWAFContext(context.Background(), o)
+}
+
+func (s *Solver) AmazonWAFContext(ctx context.Context, o AmazonWAFOptions) (*Solution, error) {
+	if s.methods.AmazonWAF == nil {
+		return nil, s.unsupported("amazonWAF")
+	}
+	ctx, done := s.begin(ctx, CaptchaAmazonWAF)
+	sol, err := s.methods.AmazonWAF(ctx, o)
+	done(sol, err)
+	return sol, err
+}
+
+// DataDome solves a datadome slider or interstitial, the datadome cookie is returned in Solution.Cookies.
+// It is only supported with capsolver.com and 2captcha
+func (s *Solver) DataDome(o DataDomeOptions) (*Solution, error) {
+	return s.DataDomeContext(context.Background(), o)
+}
+
+func (s *Solver) DataDomeContext(ctx context.Context, o DataDomeOptions) (*Solution, error) {
+	if s.methods.DataDome == nil {
+		return nil, s.unsupported("dataDome")
+	}
+	ctx, done := s.begin(ctx, CaptchaDataDome)
+	sol, err := s.methods.DataDome(ctx, o)
+	done(sol, err)
+	return sol, err
+}
+
+// Supports reports whether the service can solve the captcha type
+func (s *Solver) Supports(captchaType CaptchaType) bool {
+	m := s.methods
+
+	switch captchaType {
+	case CaptchaRecaptchaV2:
+		return m.RecaptchaV2 != nil
+	case CaptchaRecaptchaV3:
+		return m.RecaptchaV3 != nil
+	case CaptchaHCaptcha:
+		return m.HCaptcha != nil
+	case CaptchaFunCaptcha:
+		return m.FunCaptcha != nil
+	case CaptchaKasada:
+		return m.Kasada != nil
+	case CaptchaAkamai:
+		return m.Akamai != nil
+	case CaptchaCloudflare:
+		return m.Cloudflare != nil
+	case CaptchaImage:
+		return m.ImageToText != nil
+	case CaptchaGeeTest:
+		return m.GeeTest != nil
+	case CaptchaGeeTestV4:
+		return m.GeeTestV4 != nil
+	case CaptchaAmazonWAF:
+		return m.AmazonWAF != nil
+	case CaptchaDataDome:
+		return m.DataDome != nil
+	}
+
+	return false
+}
+
+// ReportBad tells the service that the solution was rejected by the site.
+// Most services refund the task, it returns an *UnsupportedError if the service can't take reports for this captcha type
+func (s *Solver) ReportBad(sol *Solution) error {
+	return s.ReportBadContext(context.Background(), sol)
+}
+
+func (s *Solver) ReportBadContext(ctx context.Context, sol *Solution) error {
+	return s.report(ctx, sol, false)
+}
+
+// ReportGood tells the service that the solution was accepted by the site
+func (s *Solver) ReportGood(sol *Solution) error {
+	return s.ReportGoodContext(context.Background(), sol)
+}
+
+func (s *Solver) ReportGoodContext(ctx context.Context, sol *Solution) error {
+	return s.report(ctx, sol, true)
+}
+
+func (s *Solver) report(ctx context.Context, sol *Solution, correct bool) error {
+	if sol == nil || sol.TaskId == nil {
+		return errors.New("solution has no task id")
+	}
+
+	if sol.Service != "" && sol.Service != s.service {
+		return fmt.Errorf("solution was solved by %s, not %s", sol.Service, s.service)
+	}
+
+	if s.methods.Report == nil {
+		return s.unsupported("report")
+	}
+
+	// a solution decoded from json has a float64 task id
+	if _, ok := sol.TaskId.(float64); ok {
+		decoded := *sol
+		decoded.TaskId = normalizeTaskId(sol.TaskId)
+		sol = &decoded
+	}
+
+	return s.methods.Report(ctx, sol, correct)
+}
+
+// begin starts a solve of captchaType, done must be called with its outcome to tag the solution,
+// record it in the solver's stats and settle its cost with the budget
+func (s *Solver) begin(ctx context.Context, captchaType CaptchaType) (context.Context, func(*Solution, error)) {
+	start := time.Now()
+	charge := s.newCharge(ctx, captchaType)
+	if charge != nil {
+		ctx = context.WithValue(ctx, chargeKey{}, charge)
+	}
+
+	return ctx, func(sol *Solution, err error) {
+		s.tagSolution(sol, captchaType)
+
+		// submitted tasks are recorded once they finish
+		if err == errSubmitted {
+			if sub := submissionFrom(ctx); sub != nil {
+				sub.charge = charge
+			}
+			return
+		}
+
+		cost := 0.0
+		if err == nil {
+			cost = s.taskCost(captchaType, sol)
+		}
+		charge.settle(cost, err)
+		s.stats.record(s.service, captchaType, time.Since(start), cost, err)
+	}
+}
+
+// tagSolution fills in the fields every solution shares
+func (s *Solver) tagSolution(sol *Solution, captchaType CaptchaType) {
+	if sol == nil {
+		return
+	}
+
+	sol.Service = s.service
+	sol.Type = captchaType
+	sol.solver = s
+}
+
+// base64 returns the image encoded as base64
+func (o ImageCaptchaOptions) base64() (string, error) {
+	if len(o.Image) > 0 {
+		return base64.StdEncoding.EncodeToString(o.Image), nil
+	}
+
+	if o.Base64 == "" {
+		return "", errors.New("image is required")
+	}
+
+	// strip data urls like "data:image/png;base64,"
+	if i := strings.Index(o.Base64, "base64,"); i != -1 && strings.HasPrefix(o.Base64, "data:") {
+		return o.Base64[i+len("base64,"):], nil
+	}
+
+	return o.Base64, nil
+}
+
+func newGeeTestV4Solution(sol *Solution, captchaId string) *GeeTestV4Solution {
+	id := rawString(sol.RawSolution, "captcha_id")
+	if id == "" {
+		id = captchaId
+	}
+
+	// gen_time is a number on some services
+	genTime := rawString(sol.RawSolution, "gen_time")
+	if n, ok := sol.RawSolution["gen_time"].(float64); ok {
+		genTime = strconv.FormatInt(int64(n), 10)
+	}
+
+	return &GeeTestV4Solution{
+		Solution:      sol,
+		CaptchaID:     id,
+		LotNumber:     rawString(sol.RawSolution, "lot_number"),
+		PassToken:     rawString(sol.RawSolution, "pass_token"),
+		GenTime:       genTime,
+		CaptchaOutput: rawString(sol.RawSolution, "captcha_output"),
+	}
+}
+
+// Values returns the solution as query values for sites that validate with a GET request
+func (s *GeeTestV4Solution) Values() url.Values {
+	return url.Values{
+		"captcha_id":     {s.CaptchaID},
+		"lot_number":     {s.LotNumber},
+		"pass_token":     {s.PassToken},
+		"gen_time":       {s.GenTime},
+		"captcha_output": {s.CaptchaOutput},
+	}
+}
+
+// amazonWAFSolution finds the aws-waf-token in the raw solution, every service returns it differently
+func amazonWAFSolution(sol *Solution) (*Solution, error) {
+	token := rawString(sol.RawSolution, "cookie")
+
+	if cookies, ok := sol.RawSolution["cookies"].(map[string]interface{}); ok && token == "" {
+		token = rawString(cookies, "aws-waf-token")
+	}
+
+	if token == "" {
+		token = rawString(sol.RawSolution, "existing_token")
+	}
+
+	if token == "" {
+		token = rawString(sol.RawSolution, "token")
+	}
+
+	if token == "" {
+		return nil, errors.New("no aws-waf-token in solution")
+	}
+
+	// some services return the whole cookie, like "aws-waf-token=..."
+	token = strings.TrimPrefix(token, "aws-waf-token=")
+
+	sol.Text = token
+	sol.Cookies = map[string]string{
+		"aws-waf-token": token,
+	}
+
+	return sol, nil
+}
+
+// dataDomeSolution extracts the datadome cookie value from a cookie like "datadome=...; Max-Age=31536000; Path=/"
+func dataDomeSolution(sol *Solution) (*Solution, error) {
+	// the cookie is either the solution text or in a cookie field
+	cookie := rawString(sol.RawSolution, "cookie")
+	if cookie == "" {
+		cookie = sol.Text
+	}
+
+	value := strings.TrimSpace(strings.Split(cookie, ";")[0])
+	value = strings.TrimPrefix(value, "datadome=")
+
+	if value == "" {
+		return nil, errors.New("no datadome cookie in solution")
+	}
+
+	sol.Text = value
+	sol.Cookies = map[string]string{
+		"datadome": value,
+	}
+
+	return sol, nil
+}
+
+func newAkamaiSolution(sol *Solution, o AkamaiOptions) *AkamaiSolution {
+	var sensors []string
+
+	// sensor_data is a single payload or a list of them
+	switch raw := sol.RawSolution["sensor_data"].(type) {
+	case string:
+		sensors = []string{raw}
+	case []interface{}:
+		for _, v := range raw {
+			if sensor, ok := v.(string); ok {
+				sensors = append(sensors, sensor)
+			}
+		}
+	}
+
+	cookies, _ := sol.RawSolution["cookies"].(map[string]interface{})
+
+	abck := rawString(cookies, "_abck")
+	bmSz := rawString(cookies, "bm_sz")
+
+	sol.Cookies = map[string]string{}
+	if abck != "" {
+		sol.Cookies["_abck"] = abck
+	}
+	if bmSz != "" {
+		sol.Cookies["bm_sz"] = bmSz
+	}
+
+	if len(sensors) > 0 {
+		sol.Text = sensors[0]
+	}
+
+	userAgent := rawString(sol.RawSolution, "user-agent")
+	if userAgent == "" {
+		userAgent = o.UserAgent
+	}
+
+	return &AkamaiSolution{
+		Solution:  sol,
+		Sensors:   sensors,
+		Abck:      abck,
+		BmSz:      bmSz,
+		UserAgent: userAgent,
+	}
+}
+
+// base returns the embedded solution, nil if s is nil
+func (s *KasadaSolution) base() *Solution {
+	if s == nil {
+		return nil
+	}
+	return s.Solution
+}
+
+func (s *AkamaiSolution) base() *Solution {
+	if s == nil {
+		return nil
+	}
+	return s.Solution
+}
+
+func (s *GeeTestSolution) base() *Solution {
+	if s == nil {
+		return nil
+	}
+	return s.Solution
+}
+
+func (s *GeeTestV4Solution) base() *Solution {
+	if s == nil {
+		return nil
+	}
+	return s.Solution
+}
+
+// reportMethod is the method name used in errors when a report isn't supported
+func reportMethod(captchaType CaptchaType, correct bool) string {
+	if correct {
+		return "reporting correct " + captchaType + " solutions"
+	}
+
+	return "reporting incorrect " + captchaType + " solutions"
+}
+
+func (s *Solver) unsupported(method string) error {
+	return &UnsupportedError{
+		Service: s.service,
+		Method:  method,
 	}
-	return s.methods.Kasada(o)
 }
 
 // RecaptchaV3Options All fields are required
@@ -116,6 +590,26 @@ type KasadaOptions struct {
 	UserAgent string
 }
 
+// AkamaiOptions Make sure to set the proxy as its required
+type AkamaiOptions struct {
+	PageURL string
+
+	// ScriptURL is the url of the akamai sensor script loaded by the page
+	ScriptURL string
+
+	// Abck is the current _abck cookie (optional)
+	Abck string
+
+	// BmSz is the current bm_sz cookie (optional)
+	BmSz string
+
+	// Proxy is required
+	Proxy *Proxy
+
+	// UserAgent Browser's User-Agent which is used in emulation. Default is random
+	UserAgent string
+}
+
 type HCaptchaOptions struct {
 	PageURL           string
 	SiteKey           string
@@ -179,12 +673,130 @@ type CloudflareOptions struct {
 	HTML string
 }
 
+// ImageCaptchaOptions only one of Image and Base64 needs to be set
+type ImageCaptchaOptions struct {
+	// Image is the raw image file
+	Image []byte
+
+	// Base64 is the base64 encoded image, used if Image is empty
+	Base64 string
+
+	// Phrase should be enabled if the answer contains at least one space
+	Phrase bool
+
+	// CaseSensitive should be enabled if the answer is case sensitive
+	CaseSensitive bool
+
+	// Numeric 0 = no preference, 1 = only numbers, 2 = no numbers
+	Numeric int
+
+	// Math should be enabled if the captcha is a math problem that needs to be calculated
+	Math bool
+
+	// MinLength and MaxLength limit the length of the answer, 0 means no limit
+	MinLength int
+	MaxLength int
+
+	// Language is the language pool of the workers, like "en" or "rn". Not every service supports every language
+	Language string
+
+	// Comment is shown to the worker, like "enter the red letters"
+	Comment string
+}
+
+// GeeTestOptions PageURL, GT and Challenge are required
+type GeeTestOptions struct {
+	PageURL string
+
+	// GT is the static gt key of the site
+	GT string
+
+	// Challenge is the dynamic key, it has to be fresh for every task
+	Challenge string
+
+	// APIServer is the api server subdomain, like api-na.geetest.com (optional)
+	APIServer string
+
+	// Proxy is optional
+	Proxy *Proxy
+
+	// UserAgent is optional
+	UserAgent string
+}
+
+// GeeTestV4Options PageURL and CaptchaID are required
+type GeeTestV4Options struct {
+	PageURL string
+
+	// CaptchaID is the captcha_id the site initializes geetest with
+	CaptchaID string
+
+	// InitParameters are extra parameters passed to initGeetest4, like "riskType" (optional)
+	InitParameters map[string]interface{}
+
+	// APIServer is the api server subdomain (optional)
+	APIServer string
+
+	// Proxy is optional
+	Proxy *Proxy
+
+	// UserAgent is optional
+	UserAgent string
+}
+
+// AmazonWAFOptions the keys can be found in the window.gokuProps object of the captcha page
+type AmazonWAFOptions struct {
+	PageURL string
+
+	// SiteKey is the gokuProps.key value
+	SiteKey string
+
+	// Iv is the gokuProps.iv value
+	Iv string
+
+	// Context is the gokuProps.context value
+	Context string
+
+	// ChallengeScript is the url of challenge.js
+	ChallengeScript string
+
+	// CaptchaScript is the url of captcha.js (optional)
+	CaptchaScript string
+
+	// Proxy is optional
+	Proxy *Proxy
+
+	// UserAgent is optional
+	UserAgent string
+}
+
+// DataDomeOptions Make sure to set the proxy as its required
+type DataDomeOptions struct {
+	// PageURL is the page that showed the block page
+	PageURL string
+
+	// CaptchaURL is the geo.captcha-delivery.com url found in the block page
+	CaptchaURL string
+
+	// UserAgent is required, the cookie only works with the same user agent
+	UserAgent string
+
+	// Proxy is required, the cookie only works with the same ip
+	Proxy *Proxy
+}
+
 type Solution struct {
 	Text string
 
 	// TaskId is normally a int, but can be a string depending on the service
 	TaskId any
 
+	// Service is the service that solved the captcha
+	Service SolveService
+
+	// Type is the kind of captcha that was solved, used when reporting the solution
+	Type CaptchaType
+
 	// RawSolution not supported on 2captcha methods
 	RawSolution map[string]interface{}
 
@@ -199,6 +811,9 @@ type Solution struct {
 
 	// IP can be "" if the service does not return IP
 	IP string
+
+	// solver is the solver that solved the captcha, so wrappers like FailoverSolver can report to it
+	solver *Solver
 }
 
 type KasadaSolution struct {
@@ -214,6 +829,63 @@ type KasadaSolution struct {
 	UserAgent string
 }
 
+type AkamaiSolution struct {
+	*Solution
+
+	// Sensors are the sensor_data payloads to post to the script url, in order
+	Sensors []string
+
+	// Abck is the updated _abck cookie, "" if the service didn't return one
+	Abck string
+
+	// BmSz is the updated bm_sz cookie, "" if the service didn't return one
+	BmSz string
+
+	// UserAgent is the user agent the sensors were generated for
+	UserAgent string
+}
+
+type GeeTestSolution struct {
+	*Solution
+
+	// Challenge is the 'geetest_challenge' field
+	Challenge string
+
+	// Validate is the 'geetest_validate' field
+	Validate string
+
+	// Seccode is the 'geetest_seccode' field
+	Seccode string
+}
+
+// GeeTestV4Solution marshals to the json body geetest's validate endpoint expects, use Values for query strings
+type GeeTestV4Solution struct {
+	*Solution `json:"-"`
+
+	CaptchaID     string `json:"captcha_id"`
+	LotNumber     string `json:"lot_number"`
+	PassToken     string `json:"pass_token"`
+	GenTime       string `json:"gen_time"`
+	CaptchaOutput string `json:"captcha_output"`
+}
+
+type CaptchaType = string
+
+const (
+	CaptchaRecaptchaV2 CaptchaType = "recaptchav2"
+	CaptchaRecaptchaV3 CaptchaType = "recaptchav3"
+	CaptchaHCaptcha    CaptchaType = "hcaptcha"
+	CaptchaFunCaptcha  CaptchaType = "funcaptcha"
+	CaptchaKasada      CaptchaType = "kasada"
+	CaptchaAkamai      CaptchaType = "akamai"
+	CaptchaCloudflare  CaptchaType = "cloudflare"
+	CaptchaImage       CaptchaType = "image"
+	CaptchaGeeTest     CaptchaType = "geetest"
+	CaptchaGeeTestV4   CaptchaType = "geetestv4"
+	CaptchaAmazonWAF   CaptchaType = "amazonwaf"
+	CaptchaDataDome    CaptchaType = "datadome"
+)
+
 type CloudflareType int
 
 const (
@@ -0,0 +1,233 @@
+package captchago_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/median/captchago"
+	"github.com/median/captchago/captchagotest"
+)
+
+var testProxy = captchago.NewProxy(captchago.ProxyTypeHTTP, "127.0.0.1", 8080, &captchago.ProxyLogon{Username: "user", Password: "pass"})
+
+// testTasks has a task of every captcha type
+var testTasks = []struct {
+	captchaType captchago.CaptchaType
+	options     captchago.TaskOptions
+}{
+	{captchago.CaptchaRecaptchaV2, captchago.RecaptchaV2Options{PageURL: "https://example.com", SiteKey: "site-key"}},
+	{captchago.CaptchaRecaptchaV3, captchago.RecaptchaV3Options{PageURL: "https://example.com", SiteKey: "site-key", MinScore: 0.7, Action: "login"}},
+	{captchago.CaptchaHCaptcha, captchago.HCaptchaOptions{PageURL: "https://example.com", SiteKey: "site-key"}},
+	{captchago.CaptchaFunCaptcha, captchago.FunCaptchaOptions{PageURL: "https://example.com", PublicKey: "public-key", UserAgent: "test"}},
+	{captchago.CaptchaCloudflare, captchago.CloudflareOptions{PageURL: "https://example.com", SiteKey: "site-key", Type: captchago.CloudflareTypeTurnstile, Proxy: testProxy}},
+	{captchago.CaptchaImage, captchago.ImageCaptchaOptions{Image: []byte("image")}},
+	{captchago.CaptchaGeeTest, captchago.GeeTestOptions{PageURL: "https://example.com", GT: "gt", Challenge: "challenge"}},
+	{captchago.CaptchaGeeTestV4, captchago.GeeTestV4Options{PageURL: "https://example.com", CaptchaID: "captcha-id"}},
+	{captchago.CaptchaAmazonWAF, captchago.AmazonWAFOptions{PageURL: "https://example.com", SiteKey: "key", Iv: "iv", Context: "context", ChallengeScript: "https://example.com/challenge.js"}},
+	{captchago.CaptchaDataDome, captchago.DataDomeOptions{PageURL: "https://example.com", CaptchaURL: "https://geo.captcha-delivery.com/captcha", UserAgent: "test", Proxy: testProxy}},
+	{captchago.CaptchaAkamai, captchago.AkamaiOptions{PageURL: "https://example.com", ScriptURL: "https://example.com/akamai.js", Proxy: testProxy}},
+	{captchago.CaptchaKasada, captchago.KasadaOptions{PageURL: "https://example.com", Proxy: testProxy}},
+}
+
+func newServer(t *testing.T) *captchagotest.Server {
+	t.Helper()
+
+	srv := captchagotest.NewServer()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSolveEveryType(t *testing.T) {
+	services := []captchago.SolveService{captchago.AntiCaptcha, captchago.CapSolver, captchago.CapMonster, captchago.AnyCaptcha, captchago.TwoCaptcha}
+
+	for _, service := range services {
+		srv := newServer(t)
+		solver := srv.Solver(service)
+
+		for _, task := range testTasks {
+			captchaType := task.captchaType
+			if !solver.Supports(captchaType) {
+				continue
+			}
+
+			t.Run(service+"/"+captchaType, func(t *testing.T) {
+				srv.Script(captchaType, captchagotest.SolveAfter(2))
+
+				sol, err := solver.Solve(context.Background(), task.options)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if sol.Service != service || sol.Type != captchaType {
+					t.Errorf("solution not tagged: service %q, type %q", sol.Service, sol.Type)
+				}
+
+				// kasada is solved in a single request, without a task
+				if sol.TaskId == nil && captchaType != captchago.CaptchaKasada {
+					t.Error("solution has no task id")
+				}
+
+				if sol.Text == "" && len(sol.RawSolution) == 0 && len(sol.Cookies) == 0 {
+					t.Error("empty solution")
+				}
+			})
+		}
+	}
+}
+
+func TestEveryTypeOnBothFamilies(t *testing.T) {
+	antiCaptcha, _ := captchago.New(captchago.CapSolver, "key")
+	twoCaptcha, _ := captchago.New(captchago.TwoCaptcha, "key")
+
+	for _, task := range testTasks {
+		captchaType := task.captchaType
+
+		switch captchaType {
+		case captchago.CaptchaKasada, captchago.CaptchaAkamai:
+			// only capsolver.com solves anti-bot tasks
+			if !antiCaptcha.Supports(captchaType) {
+				t.Errorf("capsolver doesn't support %s", captchaType)
+			}
+		default:
+			if !antiCaptcha.Supports(captchaType) || !twoCaptcha.Supports(captchaType) {
+				t.Errorf("%s isn't supported by both families", captchaType)
+			}
+		}
+	}
+}
+
+func TestCapSolverOnlyTypes(t *testing.T) {
+	// the rest of the anti-captcha family doesn't have these task types
+	for _, service := range []captchago.SolveService{captchago.AntiCaptcha, captchago.CapMonster, captchago.AnyCaptcha} {
+		solver, _ := captchago.New(service, "key")
+
+		for _, captchaType := range []captchago.CaptchaType{captchago.CaptchaKasada, captchago.CaptchaAkamai, captchago.CaptchaDataDome} {
+			if solver.Supports(captchaType) {
+				t.Errorf("%s supports %s", service, captchaType)
+			}
+		}
+	}
+}
+
+func TestTypedSolutions(t *testing.T) {
+	for _, service := range []captchago.SolveService{captchago.AntiCaptcha, captchago.TwoCaptcha} {
+		t.Run(service, func(t *testing.T) {
+			solver := newServer(t).Solver(service)
+
+			geetest, err := solver.GeeTest(captchago.GeeTestOptions{PageURL: "https://example.com", GT: "gt", Challenge: "challenge"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if geetest.Validate != captchagotest.Token {
+				t.Errorf("geetest validate %q", geetest.Validate)
+			}
+
+			v4, err := solver.GeeTestV4(captchago.GeeTestV4Options{PageURL: "https://example.com", CaptchaID: "captcha-id"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v4.PassToken != captchagotest.Token {
+				t.Errorf("geetest v4 pass token %q", v4.PassToken)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	for _, service := range []captchago.SolveService{captchago.AntiCaptcha, captchago.CapSolver, captchago.TwoCaptcha} {
+		t.Run(service, func(t *testing.T) {
+			solver := newServer(t).Solver(service)
+
+			sol, err := solver.RecaptchaV2(captchago.RecaptchaV2Options{PageURL: "https://example.com", SiteKey: "site-key"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := solver.ReportBad(sol); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	// these services don't take reports
+	for _, service := range []captchago.SolveService{captchago.CapMonster, captchago.AnyCaptcha} {
+		t.Run(service, func(t *testing.T) {
+			solver := newServer(t).Solver(service)
+
+			sol, err := solver.RecaptchaV2(captchago.RecaptchaV2Options{PageURL: "https://example.com", SiteKey: "site-key"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := solver.ReportBad(sol); !errors.Is(err, captchago.ErrUnsupported) {
+				t.Fatalf("got %v, want ErrUnsupported", err)
+			}
+		})
+	}
+}
+
+func TestReportStoredSolution(t *testing.T) {
+	for _, service := range []captchago.SolveService{captchago.AntiCaptcha, captchago.TwoCaptcha} {
+		t.Run(service, func(t *testing.T) {
+			solver := newServer(t).Solver(service)
+
+			sol, err := solver.RecaptchaV2(captchago.RecaptchaV2Options{PageURL: "https://example.com", SiteKey: "site-key"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := json.Marshal(sol)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the task id comes back as a float64
+			var stored captchago.Solution
+			if err := json.Unmarshal(data, &stored); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := solver.ReportBad(&stored); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, ok := stored.TaskId.(float64); !ok {
+				t.Errorf("report changed the stored task id to %T", stored.TaskId)
+			}
+		})
+	}
+}
+
+func TestGetBalance(t *testing.T) {
+	for _, service := range []captchago.SolveService{captchago.AntiCaptcha, captchago.TwoCaptcha} {
+		t.Run(service, func(t *testing.T) {
+			srv := newServer(t)
+			srv.SetBalance(4.5)
+
+			balance, err := srv.Solver(service).GetBalance()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if balance != 4.5 {
+				t.Errorf("balance %v, want 4.5", balance)
+			}
+		})
+	}
+}
+
+func TestCanceled(t *testing.T) {
+	srv := newServer(t)
+	srv.Script(captchago.CaptchaHCaptcha, captchagotest.SolveAfter(1000))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := srv.Solver(captchago.AntiCaptcha).HCaptchaContext(ctx, captchago.HCaptchaOptions{PageURL: "https://example.com", SiteKey: "site-key"})
+
+	var canceled *captchago.CanceledError
+	if !errors.As(err, &canceled) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want a *CanceledError", err)
+	}
+}
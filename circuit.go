@@ -0,0 +1,240 @@
+package captchago
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// CircuitClosed lets every request through
+	CircuitClosed CircuitState = "closed"
+
+	// CircuitOpen fails every task with a *CircuitOpenError without contacting the service
+	CircuitOpen CircuitState = "open"
+
+	// CircuitHalfOpen lets a single request through to check if the endpoint recovered
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// DefaultCircuitBreaker opens once half of at least 10 task creations in the last minute failed,
+// and checks the endpoint again after 30 seconds
+var DefaultCircuitBreaker = CircuitBreaker{
+	FailureRatio: 0.5,
+	MinRequests:  10,
+	Window:       time.Minute,
+	Cooldown:     time.Second * 30,
+}
+
+// CircuitBreaker stops creating tasks on an endpoint that keeps failing.
+// The state is kept per service, domain and api key, and shared by every solver using the same endpoint and key.
+type CircuitBreaker struct {
+	// FailureRatio is the fraction of failed task creations in Window that opens the breaker, between 0 and 1
+	FailureRatio float64
+
+	// MinRequests is the number of task creations in Window needed before the breaker can open
+	MinRequests int
+
+	// Window is how far back task creations are counted
+	Window time.Duration
+
+	// Cooldown is how long the breaker stays open before letting a request through again
+	Cooldown time.Duration
+
+	// IsFailure decides if an error counts against the endpoint. If nil transport errors, malformed responses,
+	// no slot and rate limit errors count, while errors about the task itself don't.
+	// Cancelled requests and errors about the account, like invalid keys and zero balance, are never counted,
+	// timed out requests are.
+	IsFailure func(err error) bool
+}
+
+type CircuitState = string
+
+// CircuitState returns the state of the circuit breaker for the current service and domain
+func (s *Solver) CircuitState() CircuitState {
+	c := s.circuit()
+	if c == nil {
+		return CircuitClosed
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == CircuitOpen && !time.Now().Before(c.retryAt) {
+		return CircuitHalfOpen
+	}
+
+	return c.state
+}
+
+// circuitKey includes the api key so a solver with a wrong key can't open the breaker of working ones
+type circuitKey struct {
+	service SolveService
+	domain  string
+	apiKey  string
+}
+
+type circuitOutcome struct {
+	at     time.Time
+	failed bool
+}
+
+// circuit is the breaker state of one endpoint
+type circuit struct {
+	mu       sync.Mutex
+	key      circuitKey
+	state    CircuitState
+	retryAt  time.Time
+	probing  bool
+	outcomes []circuitOutcome
+}
+
+var (
+	circuitsMu sync.Mutex
+	circuits   = map[circuitKey]*circuit{}
+)
+
+// circuit returns the breaker of the endpoint the solver currently uses, nil if CircuitBreaker isn't set
+func (s *Solver) circuit() *circuit {
+	if s.CircuitBreaker == nil || s.methods == nil {
+		return nil
+	}
+
+	key := circuitKey{s.service, s.methods.domain(), s.ApiKey}
+
+	circuitsMu.Lock()
+	defer circuitsMu.Unlock()
+
+	c := circuits[key]
+	if c == nil {
+		c = &circuit{key: key, state: CircuitClosed}
+		circuits[key] = c
+	}
+
+	return c
+}
+
+// allowRequest returns a *CircuitOpenError if the request shouldn't be sent, otherwise requestDone must be called with its result
+func (s *Solver) allowRequest(c *circuit) error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == CircuitClosed {
+		return nil
+	}
+
+	if c.probing || time.Now().Before(c.retryAt) {
+		return &CircuitOpenError{
+			Service: c.key.service,
+			Domain:  c.key.domain,
+			RetryAt: c.retryAt,
+		}
+	}
+
+	c.state = CircuitHalfOpen
+	c.probing = true
+	s.log(LogLevelInfo, "circuit half-open", "domain", c.key.domain)
+
+	return nil
+}
+
+// requestDone records the result of a request allowed by allowRequest
+func (s *Solver) requestDone(c *circuit, err error) {
+	if c == nil {
+		return
+	}
+
+	policy := *s.CircuitBreaker
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	failed, counted := policy.failed(err)
+
+	if c.state == CircuitHalfOpen {
+		c.probing = false
+
+		switch {
+		case !counted:
+		case failed:
+			c.open(s, now, policy)
+		default:
+			c.state = CircuitClosed
+			c.outcomes = nil
+			s.log(LogLevelInfo, "circuit closed", "domain", c.key.domain)
+		}
+		return
+	}
+
+	if !counted || c.state != CircuitClosed {
+		return
+	}
+
+	c.outcomes = append(c.outcomes, circuitOutcome{at: now, failed: failed})
+
+	drop := 0
+	for drop < len(c.outcomes) && now.Sub(c.outcomes[drop].at) > policy.Window {
+		drop++
+	}
+	c.outcomes = c.outcomes[drop:]
+
+	failures := 0
+	for _, o := range c.outcomes {
+		if o.failed {
+			failures++
+		}
+	}
+
+	if len(c.outcomes) >= policy.MinRequests && float64(failures) >= policy.FailureRatio*float64(len(c.outcomes)) && failures > 0 {
+		c.open(s, now, policy)
+	}
+}
+
+func (c *circuit) open(s *Solver, now time.Time, policy CircuitBreaker) {
+	c.state = CircuitOpen
+	c.retryAt = now.Add(policy.Cooldown)
+	c.outcomes = nil
+	s.log(LogLevelWarn, "circuit opened", "domain", c.key.domain, "retry_at", c.retryAt)
+}
+
+// failed reports whether err counts as a failure of the endpoint, counted is false if it shouldn't be counted at all
+func (p CircuitBreaker) failed(err error) (failed bool, counted bool) {
+	if err == nil {
+		return false, true
+	}
+
+	// a request that timed out counts, the endpoint may be hanging
+	if errors.Is(err, context.Canceled) {
+		return false, false
+	}
+
+	// the endpoint works, the account can't use it
+	if errors.Is(err, ErrInvalidKey) || errors.Is(err, ErrZeroBalance) {
+		return false, false
+	}
+
+	if p.IsFailure != nil {
+		return p.IsFailure(err), true
+	}
+
+	switch {
+	case errors.Is(err, ErrNoSlotAvailable),
+		errors.Is(err, ErrRateLimited),
+		errors.Is(err, ErrMalformedResponse):
+		return true, true
+	}
+
+	// the service answered, the problem is the task
+	var serviceErr *ServiceError
+	if errors.As(err, &serviceErr) {
+		return false, true
+	}
+
+	return true, true
+}
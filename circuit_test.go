@@ -0,0 +1,134 @@
+package captchago_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/median/captchago"
+	"github.com/median/captchago/captchagotest"
+)
+
+var breakerTask = captchago.RecaptchaV2Options{PageURL: "https://example.com", SiteKey: "site-key"}
+
+var testBreaker = captchago.CircuitBreaker{
+	FailureRatio: 0.5,
+	MinRequests:  2,
+	Window:       time.Minute,
+	Cooldown:     time.Millisecond * 50,
+}
+
+func breakerSolver(srv *captchagotest.Server, service captchago.SolveService) *captchago.Solver {
+	solver := srv.Solver(service)
+	solver.CircuitBreaker = &testBreaker
+	solver.RetryPolicy.CreateAttempts = 1
+	return solver
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	for _, service := range []captchago.SolveService{captchago.AntiCaptcha, captchago.TwoCaptcha} {
+		t.Run(service, func(t *testing.T) {
+			srv := newServer(t)
+			srv.Script(captchago.CaptchaRecaptchaV2, captchagotest.FailCreate("ERROR_NO_SLOT_AVAILABLE"), captchagotest.FailCreate("ERROR_NO_SLOT_AVAILABLE"))
+
+			solver := breakerSolver(srv, service)
+
+			for i := 0; i < 2; i++ {
+				if _, err := solver.RecaptchaV2(breakerTask); !errors.Is(err, captchago.ErrNoSlotAvailable) {
+					t.Fatalf("got %v, want ErrNoSlotAvailable", err)
+				}
+			}
+
+			if state := solver.CircuitState(); state != captchago.CircuitOpen {
+				t.Fatalf("state %s, want open", state)
+			}
+
+			_, err := solver.RecaptchaV2(breakerTask)
+
+			var open *captchago.CircuitOpenError
+			if !errors.As(err, &open) || !errors.Is(err, captchago.ErrCircuitOpen) {
+				t.Fatalf("got %v, want a *CircuitOpenError", err)
+			}
+
+			if n := len(srv.Tasks()); n != 2 {
+				t.Errorf("%d tasks sent, the open breaker should have stopped the third", n)
+			}
+
+			time.Sleep(testBreaker.Cooldown)
+
+			if state := solver.CircuitState(); state != captchago.CircuitHalfOpen {
+				t.Fatalf("state %s after the cooldown, want half-open", state)
+			}
+
+			// the probe succeeds and closes the breaker
+			if _, err := solver.RecaptchaV2(breakerTask); err != nil {
+				t.Fatal(err)
+			}
+
+			if state := solver.CircuitState(); state != captchago.CircuitClosed {
+				t.Fatalf("state %s, want closed", state)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerAccountErrors(t *testing.T) {
+	srv := newServer(t)
+
+	wrongKey := breakerSolver(srv, captchago.AntiCaptcha)
+	wrongKey.ApiKey = "wrong-key"
+
+	for i := 0; i < 5; i++ {
+		if _, err := wrongKey.RecaptchaV2(breakerTask); !errors.Is(err, captchago.ErrInvalidKey) {
+			t.Fatalf("got %v, want ErrInvalidKey", err)
+		}
+	}
+
+	// the endpoint works, only the key is wrong
+	if state := wrongKey.CircuitState(); state != captchago.CircuitClosed {
+		t.Errorf("wrong key opened its breaker")
+	}
+
+	if _, err := breakerSolver(srv, captchago.AntiCaptcha).RecaptchaV2(breakerTask); err != nil {
+		t.Fatalf("a wrong key broke the solver with the right key: %v", err)
+	}
+}
+
+func TestCircuitBreakerPerKey(t *testing.T) {
+	srv := newServer(t)
+	srv.SetDefault(captchagotest.FailCreate("ERROR_NO_SLOT_AVAILABLE"))
+
+	solver := breakerSolver(srv, captchago.AntiCaptcha)
+	for i := 0; i < 2; i++ {
+		_, _ = solver.RecaptchaV2(breakerTask)
+	}
+
+	if solver.CircuitState() != captchago.CircuitOpen {
+		t.Fatal("breaker didn't open")
+	}
+
+	other := breakerSolver(srv, captchago.AntiCaptcha)
+	other.ApiKey = "other-key"
+
+	if state := other.CircuitState(); state != captchago.CircuitClosed {
+		t.Errorf("another key's breaker is %s", state)
+	}
+}
+
+func TestCircuitBreakerKasada(t *testing.T) {
+	srv := newServer(t)
+	srv.SetDefault(captchagotest.FailCreate("ERROR_NO_SLOT_AVAILABLE"))
+
+	solver := breakerSolver(srv, captchago.CapSolver)
+	task := captchago.KasadaOptions{PageURL: "https://example.com", Proxy: testProxy}
+
+	for i := 0; i < 2; i++ {
+		if _, err := solver.Kasada(task); !errors.Is(err, captchago.ErrNoSlotAvailable) {
+			t.Fatalf("got %v, want ErrNoSlotAvailable", err)
+		}
+	}
+
+	if _, err := solver.Kasada(task); !errors.Is(err, captchago.ErrCircuitOpen) {
+		t.Fatalf("got %v, want ErrCircuitOpen", err)
+	}
+}
@@ -2,12 +2,15 @@ package captchago
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
 func cookiesToString(input map[string]string) string {
@@ -24,13 +27,38 @@ func cookiesToString(input map[string]string) string {
 	return output
 }
 
-func postJSON(url string, data map[string]interface{}) (map[string]interface{}, error) {
+// rawString returns the string stored under key, or "" if it's missing or not a string
+func rawString(raw map[string]interface{}, key string) string {
+	str, _ := raw[key].(string)
+	return str
+}
+
+// sleepContext waits for d to pass, returning early with ctx.Err() if ctx is done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, data map[string]interface{}) (map[string]interface{}, error) {
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -42,43 +70,83 @@ func postJSON(url string, data map[string]interface{}) (map[string]interface{},
 	}
 
 	var output map[string]interface{}
+	jsonErr := json.Unmarshal(body, &output)
 
-	err = json.Unmarshal(body, &output)
-	if err != nil {
-		return nil, err
+	if err := checkStatus(resp, body); err != nil {
+		// some services send their own errors with a 4xx status, those are still parsed
+		if errorId, _ := output["errorId"].(float64); errorId == 0 {
+			return nil, err
+		}
+	}
+
+	if jsonErr != nil {
+		return nil, jsonErr
 	}
 
-	return output, err
+	return output, nil
 }
 
-func postQuery(link string, data map[string]interface{}) (string, error) {
+// encodeQuery encodes data as a url query without the leading "?"
+func encodeQuery(data map[string]interface{}) string {
 	var querys string
 
-	if data != nil {
-		for k, v := range data {
-			str := ""
-
-			switch v.(type) {
-			case string:
-				str = v.(string)
-				break
-			case int:
-				str = strconv.Itoa(v.(int))
-				break
-			case float64:
-				str = fmt.Sprintf("%.3f", v.(float64))
-				break
-			}
-
-			querys += url.QueryEscape(k) + "=" + url.QueryEscape(str) + "&"
+	for k, v := range data {
+		str := ""
+
+		switch v.(type) {
+		case string:
+			str = v.(string)
+			break
+		case int:
+			str = strconv.Itoa(v.(int))
+			break
+		case float64:
+			str = fmt.Sprintf("%.3f", v.(float64))
+			break
 		}
 
+		querys += url.QueryEscape(k) + "=" + url.QueryEscape(str) + "&"
+	}
+
+	if len(querys) > 0 {
+		querys = querys[:len(querys)-1]
+	}
+
+	return querys
+}
+
+func postQuery(ctx context.Context, client *http.Client, link string, data map[string]interface{}) (string, error) {
+	var querys string
+
+	if data != nil {
+		querys = encodeQuery(data)
 		if len(querys) > 0 {
-			querys = "?" + querys[:len(querys)-1]
+			querys = "?" + querys
 		}
 	}
 
-	resp, err := http.Get(link + querys)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link+querys, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return doText(client, req)
+}
+
+// postForm is postQuery but sends data as a form body, used for payloads too large for a url
+func postForm(ctx context.Context, client *http.Client, link string, data map[string]interface{}) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, link, strings.NewReader(encodeQuery(data)))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return doText(client, req)
+}
+
+// doText sends req and returns the response body as a string
+func doText(client *http.Client, req *http.Request) (string, error) {
+	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -90,5 +158,23 @@ func postQuery(link string, data map[string]interface{}) (string, error) {
 		return "", err
 	}
 
+	if err := checkStatus(resp, body); err != nil {
+		return "", err
+	}
+
 	return string(body), nil
 }
+
+// checkStatus returns a *StatusError if resp doesn't have a 2xx status
+func checkStatus(resp *http.Response, body []byte) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	text := strings.TrimSpace(string(body))
+	if len(text) > 100 {
+		text = text[:100] + "..."
+	}
+
+	return &StatusError{StatusCode: resp.StatusCode, Body: text}
+}
@@ -0,0 +1,283 @@
+package captchago
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrInvalidKey is returned when the api key is missing, wrong or blocked
+	ErrInvalidKey = errors.New("invalid api key")
+
+	// ErrZeroBalance is returned when the account doesn't have enough funds to create a task
+	ErrZeroBalance = errors.New("zero balance")
+
+	// ErrNoSlotAvailable is returned when the service has no free workers, retrying later usually works
+	ErrNoSlotAvailable = errors.New("no slot available")
+
+	// ErrWrongSiteKey is returned when the site key (or public key) is invalid for the page
+	ErrWrongSiteKey = errors.New("wrong site key")
+
+	// ErrUnsolvable is returned when the workers couldn't solve the captcha
+	ErrUnsolvable = errors.New("captcha unsolvable")
+
+	// ErrProxyBanned is returned when the service couldn't use the supplied proxy
+	ErrProxyBanned = errors.New("proxy banned or unreachable")
+
+	// ErrRateLimited is returned when requests are sent to the service too quickly
+	ErrRateLimited = errors.New("rate limited")
+
+	// ErrTaskNotFound is returned when the service doesn't know the task id, or it has expired
+	ErrTaskNotFound = errors.New("task not found")
+)
+
+// ErrMalformedResponse is returned when the service answered with something that can't be used
+var ErrMalformedResponse = errors.New("malformed response")
+
+// ErrUnsupported is returned when the service doesn't support a method, check with errors.Is
+var ErrUnsupported = errors.New("not supported by this service")
+
+// ErrCircuitOpen is returned without contacting the service while its circuit breaker is open, see Solver.CircuitBreaker
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// ErrBudgetExceeded is returned without creating a task once a limit of the solver's budget is reached, see Solver.Budget
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// errorCodes maps the error codes of every supported service to their sentinel error.
+// The anti-captcha family and 2captcha share most of their codes, so one table covers both.
+var errorCodes = map[string]error{
+	// invalid key
+	"ERROR_KEY_DOES_NOT_EXIST":    ErrInvalidKey,
+	"ERROR_WRONG_USER_KEY":        ErrInvalidKey,
+	"ERROR_KEY_DENIED_ACCESS":     ErrInvalidKey,
+	"ERROR_ACCOUNT_SUSPENDED":     ErrInvalidKey,
+	"ERROR_IP_NOT_ALLOWED":        ErrInvalidKey,
+	"ERROR_INVALID_CLIENT_KEY":    ErrInvalidKey,
+	"ERROR_KEY_TEMPORARY_BLOCKED": ErrInvalidKey,
+
+	// zero balance
+	"ERROR_ZERO_BALANCE":      ErrZeroBalance,
+	"ERROR_SETTLEMENT_FAILED": ErrZeroBalance,
+
+	// no slot
+	"ERROR_NO_SLOT_AVAILABLE": ErrNoSlotAvailable,
+
+	// wrong site key
+	"ERROR_RECAPTCHA_INVALID_SITEKEY": ErrWrongSiteKey,
+	"ERROR_WRONG_GOOGLEKEY":           ErrWrongSiteKey,
+	"ERROR_GOOGLEKEY":                 ErrWrongSiteKey,
+	"ERROR_SITEKEY":                   ErrWrongSiteKey,
+	"ERROR_INVALID_SITEKEY":           ErrWrongSiteKey,
+
+	// unsolvable
+	"ERROR_CAPTCHA_UNSOLVABLE":   ErrUnsolvable,
+	"ERROR_CAPTCHA_SOLVE_FAILED": ErrUnsolvable,
+	"ERROR_BAD_DUPLICATES":       ErrUnsolvable,
+
+	// proxy
+	"ERROR_PROXY_BANNED":                    ErrProxyBanned,
+	"ERROR_PROXY_CONNECT_REFUSED":           ErrProxyBanned,
+	"ERROR_PROXY_CONNECTION_FAILED":         ErrProxyBanned,
+	"ERROR_PROXY_TIMEOUT":                   ErrProxyBanned,
+	"ERROR_PROXY_READ_TIMEOUT":              ErrProxyBanned,
+	"ERROR_PROXY_TRANSPARENT":               ErrProxyBanned,
+	"ERROR_PROXY_NOT_AUTHORISED":            ErrProxyBanned,
+	"ERROR_PROXY_INCOMPATIBLE_HTTP_VERSION": ErrProxyBanned,
+	"ERROR_BAD_PROXY":                       ErrProxyBanned,
+
+	// rate limit, 2captcha answers "ERROR: 100x" while it blocks the account for sending too many failing requests
+	"ERROR_TOO_MUCH_REQUESTS": ErrRateLimited,
+	"ERROR_IP_BANNED":         ErrRateLimited,
+	"ERROR_IP_BLOCKED":        ErrRateLimited,
+	"IP_BANNED":               ErrRateLimited,
+	"ERROR_RATE_LIMIT":        ErrRateLimited,
+	"MAX_USER_TURN":           ErrRateLimited,
+	"ERROR: 1001":             ErrRateLimited,
+	"ERROR: 1002":             ErrZeroBalance,
+	"ERROR: 1003":             ErrRateLimited,
+	"ERROR: 1004":             ErrRateLimited,
+	"ERROR: 1005":             ErrRateLimited,
+
+	// unsupported, the service doesn't solve this task type
+	"ERROR_TASK_NOT_SUPPORTED": ErrUnsupported,
+	"ERROR_NO_SUCH_METHOD":     ErrUnsupported,
+
+	// task not found
+	"ERROR_NO_SUCH_CAPCHA_ID": ErrTaskNotFound,
+	"ERROR_WRONG_CAPTCHA_ID":  ErrTaskNotFound,
+	"ERROR_TASKID_INVALID":    ErrTaskNotFound,
+}
+
+// blockingCodes are rate limits that block the account or ip for minutes, retrying them right away only extends the block
+var blockingCodes = map[string]bool{
+	"ERROR_IP_BANNED":  true,
+	"ERROR_IP_BLOCKED": true,
+	"IP_BANNED":        true,
+	"ERROR: 1001":      true,
+	"ERROR: 1004":      true,
+	"ERROR: 1005":      true,
+}
+
+// ServiceError is an error returned by the captcha service itself.
+// Use errors.Is with the Err* sentinels to check what went wrong regardless of the service.
+type ServiceError struct {
+	// Service is the service that returned the error
+	Service SolveService
+
+	// Code is the raw error code, like ERROR_ZERO_BALANCE
+	Code string
+
+	// Description is the human readable error, it can be the same as Code
+	Description string
+
+	// TaskId is the task the error belongs to, nil if the task wasn't created
+	TaskId any
+
+	// Err is the sentinel error the code maps to, nil if the code is unknown
+	Err error
+}
+
+func newServiceError(service SolveService, code, description string, taskId any) *ServiceError {
+	return &ServiceError{
+		Service:     service,
+		Code:        code,
+		Description: description,
+		TaskId:      taskId,
+		Err:         errorCodes[code],
+	}
+}
+
+func (e *ServiceError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("%s: %s", e.Service, e.Description)
+	}
+
+	if e.Description != "" && e.Description != e.Code {
+		return fmt.Sprintf("%s: %s: %s", e.Service, e.Code, e.Description)
+	}
+
+	return fmt.Sprintf("%s: %s", e.Service, e.Code)
+}
+
+func (e *ServiceError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable returns true if sending the same task again may succeed
+func (e *ServiceError) Retryable() bool {
+	if blockingCodes[e.Code] {
+		return false
+	}
+
+	switch e.Err {
+	case ErrNoSlotAvailable, ErrUnsolvable, ErrRateLimited:
+		return true
+	}
+
+	return false
+}
+
+// Temporary is the same as Retryable, it's here to match the net.Error convention
+func (e *ServiceError) Temporary() bool {
+	return e.Retryable()
+}
+
+// IsRetryable reports whether err, or any error it wraps, is classified as retryable
+func IsRetryable(err error) bool {
+	var r interface{ Retryable() bool }
+	if errors.As(err, &r) {
+		return r.Retryable()
+	}
+
+	return false
+}
+
+// StatusError is returned when the service answers with a non 2xx http status, like an error page from a gateway in front of it.
+// It's retried like a transport error, see RetryPolicy
+type StatusError struct {
+	StatusCode int
+
+	// Body is the start of the response body
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("http status %d", e.StatusCode)
+	}
+
+	return fmt.Sprintf("http status %d: %s", e.StatusCode, e.Body)
+}
+
+// UnsupportedError is returned when the configured service doesn't implement a method
+type UnsupportedError struct {
+	Service SolveService
+	Method  string
+}
+
+func (e *UnsupportedError) Error() string {
+	return "service does not support " + e.Method
+}
+
+func (e *UnsupportedError) Unwrap() error {
+	return ErrUnsupported
+}
+
+// CircuitOpenError is returned when a task isn't created because the circuit breaker of the endpoint is open
+type CircuitOpenError struct {
+	Service SolveService
+	Domain  string
+
+	// RetryAt is when the breaker lets a request through again to check if the endpoint recovered
+	RetryAt time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s: circuit breaker open for %s until %s", e.Service, e.Domain, e.RetryAt.Format(time.RFC3339))
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// CanceledError is returned when a solve is stopped because its context was cancelled or its deadline expired.
+// It unwraps to the context's error, so errors.Is(err, context.Canceled) and
+// errors.Is(err, context.DeadlineExceeded) work as expected.
+type CanceledError struct {
+	// TaskId is the id of the task that was being polled, nil if the task was never created
+	TaskId any
+
+	// Err is the error returned by the context
+	Err error
+}
+
+func (e *CanceledError) Error() string {
+	if e.TaskId == nil {
+		return "captcha solve canceled: " + e.Err.Error()
+	}
+
+	return fmt.Sprintf("captcha solve canceled for task %v: %s", e.TaskId, e.Err)
+}
+
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
+// malformed returns an error wrapping ErrMalformedResponse
+func malformed(reason string) error {
+	return fmt.Errorf("%w: %s", ErrMalformedResponse, reason)
+}
+
+// contextError returns a *CanceledError if ctx is done, otherwise it returns err unchanged
+func contextError(ctx context.Context, taskId any, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return &CanceledError{
+			TaskId: taskId,
+			Err:    ctxErr,
+		}
+	}
+
+	return err
+}
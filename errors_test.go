@@ -0,0 +1,70 @@
+package captchago_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/median/captchago"
+	"github.com/median/captchago/captchagotest"
+)
+
+func TestServiceErrors(t *testing.T) {
+	tests := []struct {
+		service   captchago.SolveService
+		outcome   captchagotest.Outcome
+		sentinel  error
+		retryable bool
+	}{
+		{captchago.AntiCaptcha, captchagotest.FailCreate("ERROR_ZERO_BALANCE"), captchago.ErrZeroBalance, false},
+		{captchago.AntiCaptcha, captchagotest.FailWith(1, "ERROR_CAPTCHA_UNSOLVABLE"), captchago.ErrUnsolvable, true},
+		{captchago.AntiCaptcha, captchagotest.FailCreate("ERROR_TASK_NOT_SUPPORTED"), captchago.ErrUnsupported, false},
+		{captchago.AntiCaptcha, captchagotest.FailCreate("ERROR_IP_BLOCKED"), captchago.ErrRateLimited, false},
+		{captchago.CapSolver, captchagotest.FailCreate("ERROR_SETTLEMENT_FAILED"), captchago.ErrZeroBalance, false},
+		{captchago.TwoCaptcha, captchagotest.FailCreate("ERROR_WRONG_GOOGLEKEY"), captchago.ErrWrongSiteKey, false},
+		{captchago.TwoCaptcha, captchagotest.FailCreate("MAX_USER_TURN"), captchago.ErrRateLimited, true},
+		{captchago.TwoCaptcha, captchagotest.FailCreate("ERROR: 1001"), captchago.ErrRateLimited, false},
+		{captchago.TwoCaptcha, captchagotest.FailCreate("ERROR: 1002"), captchago.ErrZeroBalance, false},
+		{captchago.TwoCaptcha, captchagotest.FailCreate("ERROR: 1003"), captchago.ErrRateLimited, true},
+		{captchago.TwoCaptcha, captchagotest.FailCreate("IP_BANNED"), captchago.ErrRateLimited, false},
+		{captchago.TwoCaptcha, captchagotest.FailCreate("ERROR_NO_SUCH_METHOD"), captchago.ErrUnsupported, false},
+		{captchago.TwoCaptcha, captchagotest.FailWith(1, "ERROR_BAD_DUPLICATES"), captchago.ErrUnsolvable, true},
+	}
+
+	for _, test := range tests {
+		code := test.outcome.CreateError + test.outcome.Error
+		t.Run(test.service+"/"+code, func(t *testing.T) {
+			srv := newServer(t)
+			solver := srv.Solver(test.service)
+			solver.RetryPolicy.CreateAttempts = 1
+
+			srv.Script(captchago.CaptchaHCaptcha, test.outcome)
+
+			_, err := solver.HCaptcha(captchago.HCaptchaOptions{PageURL: "https://example.com", SiteKey: "site-key"})
+			if !errors.Is(err, test.sentinel) {
+				t.Fatalf("got %v, want %v", err, test.sentinel)
+			}
+
+			var serviceErr *captchago.ServiceError
+			if !errors.As(err, &serviceErr) || serviceErr.Code != code {
+				t.Fatalf("got %#v, want a *ServiceError with code %q", err, code)
+			}
+
+			if captchago.IsRetryable(err) != test.retryable {
+				t.Errorf("retryable %v, want %v", !test.retryable, test.retryable)
+			}
+		})
+	}
+}
+
+func TestMalformedResponse(t *testing.T) {
+	srv := newServer(t)
+	solver := srv.Solver(captchago.TwoCaptcha)
+	solver.RetryPolicy.MaxPollFailures = 0
+
+	srv.Script(captchago.CaptchaHCaptcha, captchagotest.Malformed("<html><body>502 Bad Gateway</body></html>"))
+
+	_, err := solver.HCaptcha(captchago.HCaptchaOptions{PageURL: "https://example.com", SiteKey: "site-key"})
+	if !errors.Is(err, captchago.ErrMalformedResponse) {
+		t.Fatalf("got %v, want ErrMalformedResponse", err)
+	}
+}
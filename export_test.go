@@ -0,0 +1,8 @@
+package captchago
+
+import "time"
+
+// Backoff exposes RetryPolicy.backoff to the external tests
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	return p.backoff(retry)
+}
@@ -0,0 +1,310 @@
+package captchago
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// NewFailoverSolver returns a solver that tries every backend in order until one solves the captcha.
+// Every backend is a normal Solver, so each can have its own service, key and options
+func NewFailoverSolver(backends ...*Solver) *FailoverSolver {
+	return &FailoverSolver{
+		Backends: backends,
+	}
+}
+
+// DefaultShouldFailover moves on to the next backend when the current one can't take the task right now,
+// doesn't support it or timed out
+func DefaultShouldFailover(err error) bool {
+	switch {
+	case errors.Is(err, ErrZeroBalance),
+		errors.Is(err, ErrNoSlotAvailable),
+		errors.Is(err, ErrInvalidKey),
+		errors.Is(err, ErrRateLimited),
+		errors.Is(err, ErrUnsupported),
+		errors.Is(err, ErrCircuitOpen),
+		errors.Is(err, ErrBudgetExceeded),
+		errors.Is(err, context.DeadlineExceeded):
+		return true
+	}
+
+	return false
+}
+
+// GetBalance returns the sum of the balances of every backend
+func (f *FailoverSolver) GetBalance() (float64, error) {
+	return f.GetBalanceContext(context.Background())
+}
+
+// GetBalanceContext is GetBalance but stops once ctx is done.
+// Backends that can't report their balance are skipped, any other error is returned
+func (f *FailoverSolver) GetBalanceContext(ctx context.Context) (float64, error) {
+	total := 0.0
+	for _, s := range f.Backends {
+		balance, err := s.GetBalanceContext(ctx)
+		if errors.Is(err, ErrUnsupported) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		total += balance
+	}
+
+	return total, nil
+}
+
+// Supports reports whether any backend can solve the captcha type
+func (f *FailoverSolver) Supports(captchaType CaptchaType) bool {
+	for _, s := range f.Backends {
+		if s.Supports(captchaType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// failover calls solve with every backend in order until one succeeds or returns an error that shouldn't fail over
+func failover[T any](ctx context.Context, f *FailoverSolver, solve func(context.Context, *Solver) (T, error)) (T, error) {
+	var result T
+	err := errors.New("failover solver has no backends")
+
+	shouldFailover := f.ShouldFailover
+	if shouldFailover == nil {
+		shouldFailover = DefaultShouldFailover
+	}
+
+	for i, s := range f.Backends {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if f.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, f.Timeout)
+		}
+
+		result, err = solve(attemptCtx, s)
+		cancel()
+
+		// the caller gave up, the other backends would time out too
+		if err == nil || ctx.Err() != nil || !shouldFailover(err) {
+			return result, err
+		}
+
+		if i+1 < len(f.Backends) {
+			s.log(LogLevelWarn, "failing over", "error", err, "next_service", f.Backends[i+1].service)
+		}
+	}
+
+	return result, err
+}
+
+func (f *FailoverSolver) RecaptchaV2(o RecaptchaV2Options) (*Solution, error) {
+	return f.RecaptchaV2Context(context.Background(), o)
+}
+
+func (f *FailoverSolver) RecaptchaV2Context(ctx context.Context, o RecaptchaV2Options) (*Solution, error) {
+	return failover(ctx, f, func(ctx context.Context, s *Solver) (*Solution, error) {
+		return s.RecaptchaV2Context(ctx, o)
+	})
+}
+
+func (f *FailoverSolver) RecaptchaV3(o RecaptchaV3Options) (*Solution, error) {
+	return f.RecaptchaV3Context(context.Background(), o)
+}
+
+func (f *FailoverSolver) RecaptchaV3Context(ctx context.Context, o RecaptchaV3Options) (*Solution, error) {
+	return failover(ctx, f, func(ctx context.Context, s *Solver) (*Solution, error) {
+		return s.RecaptchaV3Context(ctx, o)
+	})
+}
+
+func (f *FailoverSolver) HCaptcha(o HCaptchaOptions) (*Solution, error) {
+	return f.HCaptchaContext(context.Background(), o)
+}
+
+func (f *FailoverSolver) HCaptchaContext(ctx context.Context, o HCaptchaOptions) (*Solution, error) {
+	return failover(ctx, f, func(ctx context.Context, s *Solver) (*Solution, error) {
+		return s.HCaptchaContext(ctx, o)
+	})
+}
+
+func (f *FailoverSolver) FunCaptcha(o FunCaptchaOptions) (*Solution, error) {
+	return f.FunCaptchaContext(context.Background(), o)
+}
+
+func (f *FailoverSolver) FunCaptchaContext(ctx context.Context, o FunCaptchaOptions) (*Solution, error) {
+	return failover(ctx, f, func(ctx context.Context, s *Solver) (*Solution, error) {
+		return s.FunCaptchaContext(ctx, o)
+	})
+}
+
+func (f *FailoverSolver) Cloudflare(o CloudflareOptions) (*Solution, error) {
+	return f.CloudflareContext(context.Background(), o)
+}
+
+func (f *FailoverSolver) CloudflareContext(ctx context.Context, o CloudflareOptions) (*Solution, error) {
+	return failover(ctx, f, func(ctx context.Context, s *Solver) (*Solution, error) {
+		return s.CloudflareContext(ctx, o)
+	})
+}
+
+func (f *FailoverSolver) Kasada(o KasadaOptions) (*KasadaSolution, error) {
+	return f.KasadaContext(context.Background(), o)
+}
+
+func (f *FailoverSolver) KasadaContext(ctx context.Context, o KasadaOptions) (*KasadaSolution, error) {
+	return failover(ctx, f, func(ctx context.Context, s *Solver) (*KasadaSolution, error) {
+		return s.KasadaContext(ctx, o)
+	})
+}
+
+func (f *FailoverSolver) Akamai(o AkamaiOptions) (*AkamaiSolution, error) {
+	return f.AkamaiContext(context.Background(), o)
+}
+
+func (f *FailoverSolver) AkamaiContext(ctx context.Context, o AkamaiOptions) (*AkamaiSolution, error) {
+	return failover(ctx, f, func(ctx context.Context, s *Solver) (*AkamaiSolution, error) {
+		return s.AkamaiContext(ctx, o)
+	})
+}
+
+func (f *FailoverSolver) ImageToText(o ImageCaptchaOptions) (*Solution, error) {
+	return f.ImageToTextContext(context.Background(), o)
+}
+
+func (f *FailoverSolver) ImageToTextContext(ctx context.Context, o ImageCaptchaOptions) (*Solution, error) {
+	return failover(ctx, f, func(ctx context.Context, s *Solver) (*Solution, error) {
+		return s.ImageToTextContext(ctx, o)
+	})
+}
+
+func (f *FailoverSolver) GeeTest(o GeeTestOptions) (*GeeTestSolution, error) {
+	return f.GeeTestContext(context.Background(), o)
+}
+
+func (f *FailoverSolver) GeeTestContext(ctx context.Context, o GeeTestOptions) (*GeeTestSolution, error) {
+	return failover(ctx, f, func(ctx context.Context, s *Solver) (*GeeTestSolution, error) {
+		return s.GeeTestContext(ctx, o)
+	})
+}
+
+func (f *FailoverSolver) GeeTestV4(o GeeTestV4Options) (*GeeTestV4Solution, error) {
+	return f.GeeTestV4Context(context.Background(), o)
+}
+
+func (f *FailoverSolver) GeeTestV4Context(ctx context.Context, o GeeTestV4Options) (*GeeTestV4Solution, error) {
+	return failover(ctx, f, func(ctx context.Context, s *Solver) (*GeeTestV4Solution, error) {
+		return s.GeeTestV4Context(ctx, o)
+	})
+}
+
+func (f *FailoverSolver) AmazonWAF(o AmazonWAFOptions) (*Solution, error) {
+	return f.AmazonWAFContext(context.Background(), o)
+}
+
+func (f *FailoverSolver) AmazonWAFContext(ctx context.Context, o AmazonWAFOptions) (*Solution, error) {
+	return failover(ctx, f, func(ctx context.Context, s *Solver) (*Solution, error) {
+		return s.AmazonWAFContext(ctx, o)
+	})
+}
+
+func (f *FailoverSolver) DataDome(o DataDomeOptions) (*Solution, error) {
+	return f.DataDomeContext(context.Background(), o)
+}
+
+func (f *FailoverSolver) DataDomeContext(ctx context.Context, o DataDomeOptions) (*Solution, error) {
+	return failover(ctx, f, func(ctx context.Context, s *Solver) (*Solution, error) {
+		return s.DataDomeContext(ctx, o)
+	})
+}
+
+// Solve solves o with the first backend that can, see Solver.Solve
+func (f *FailoverSolver) Solve(ctx context.Context, o TaskOptions) (*Solution, error) {
+	return failover(ctx, f, func(ctx context.Context, s *Solver) (*Solution, error) {
+		return s.Solve(ctx, o)
+	})
+}
+
+// Submit submits o to the first backend that accepts it, the task keeps polling that backend
+func (f *FailoverSolver) Submit(ctx context.Context, o TaskOptions) (*Task, error) {
+	return failover(ctx, f, func(ctx context.Context, s *Solver) (*Task, error) {
+		return s.Submit(ctx, o)
+	})
+}
+
+func (f *FailoverSolver) SolveBatch(ctx context.Context, tasks []TaskOptions, o BatchOptions) []BatchResult {
+	return solveBatch(ctx, tasks, o, f.Solve)
+}
+
+// ReportBad reports the solution to the backend that solved it
+func (f *FailoverSolver) ReportBad(sol *Solution) error {
+	return f.ReportBadContext(context.Background(), sol)
+}
+
+func (f *FailoverSolver) ReportBadContext(ctx context.Context, sol *Solution) error {
+	s, err := f.backendFor(sol)
+	if err != nil {
+		return err
+	}
+	return s.ReportBadContext(ctx, sol)
+}
+
+// ReportGood reports the solution to the backend that solved it
+func (f *FailoverSolver) ReportGood(sol *Solution) error {
+	return f.ReportGoodContext(context.Background(), sol)
+}
+
+func (f *FailoverSolver) ReportGoodContext(ctx context.Context, sol *Solution) error {
+	s, err := f.backendFor(sol)
+	if err != nil {
+		return err
+	}
+	return s.ReportGoodContext(ctx, sol)
+}
+
+// backendFor returns the backend that solved sol
+func (f *FailoverSolver) backendFor(sol *Solution) (*Solver, error) {
+	if sol == nil {
+		return nil, errors.New("solution has no task id")
+	}
+
+	for _, s := range f.Backends {
+		if s == sol.solver {
+			return s, nil
+		}
+	}
+
+	// solutions that weren't solved by a backend, like ones decoded from json, are matched by service
+	// when it's not ambiguous
+	var match *Solver
+	for _, s := range f.Backends {
+		if s.service != sol.Service {
+			continue
+		}
+
+		if match != nil {
+			return nil, errors.New("more than one backend uses " + sol.Service)
+		}
+		match = s
+	}
+
+	if match == nil {
+		return nil, errors.New("no backend uses " + sol.Service)
+	}
+
+	return match, nil
+}
+
+// FailoverSolver has the same methods as Solver but moves on to the next backend when one fails.
+// Solution.Service records which backend solved the captcha
+type FailoverSolver struct {
+	// Backends are tried in order
+	Backends []*Solver
+
+	// Timeout limits how long each backend can take before moving on, 0 means no limit
+	Timeout time.Duration
+
+	// ShouldFailover decides if an error moves on to the next backend, DefaultShouldFailover is used if nil
+	ShouldFailover func(err error) bool
+}
@@ -0,0 +1,112 @@
+package captchago_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/median/captchago"
+	"github.com/median/captchago/captchagotest"
+)
+
+func countPath(srv *captchagotest.Server, path string) int {
+	n := 0
+	for _, r := range srv.Requests() {
+		if r.Path == path {
+			n++
+		}
+	}
+	return n
+}
+
+func TestFailover(t *testing.T) {
+	// the first backend can't solve the task, the next one can
+	for _, code := range []string{"ERROR_ZERO_BALANCE", "ERROR_TASK_NOT_SUPPORTED"} {
+		t.Run(code, func(t *testing.T) {
+			first, second := newServer(t), newServer(t)
+			first.SetDefault(captchagotest.FailCreate(code))
+
+			f := captchago.NewFailoverSolver(first.Solver(captchago.AntiCaptcha), second.Solver(captchago.TwoCaptcha))
+
+			sol, err := f.HCaptcha(captchago.HCaptchaOptions{PageURL: "https://example.com", SiteKey: "site-key"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if sol.Service != captchago.TwoCaptcha {
+				t.Errorf("solved by %s, want 2captcha", sol.Service)
+			}
+		})
+	}
+}
+
+func TestFailoverStops(t *testing.T) {
+	first, second := newServer(t), newServer(t)
+	first.SetDefault(captchagotest.FailCreate("ERROR_WRONG_GOOGLEKEY"))
+
+	f := captchago.NewFailoverSolver(first.Solver(captchago.AntiCaptcha), second.Solver(captchago.AntiCaptcha))
+
+	// another backend won't accept the site key either
+	_, err := f.RecaptchaV2(captchago.RecaptchaV2Options{PageURL: "https://example.com", SiteKey: "site-key"})
+	if !errors.Is(err, captchago.ErrWrongSiteKey) {
+		t.Fatalf("got %v, want ErrWrongSiteKey", err)
+	}
+
+	if n := len(second.Tasks()); n != 0 {
+		t.Errorf("second backend got %d tasks", n)
+	}
+}
+
+func TestFailoverReportSameService(t *testing.T) {
+	first, second := newServer(t), newServer(t)
+	first.SetDefault(captchagotest.FailCreate("ERROR_NO_SLOT_AVAILABLE"))
+
+	firstSolver := first.Solver(captchago.AntiCaptcha)
+	firstSolver.RetryPolicy.CreateAttempts = 1
+
+	f := captchago.NewFailoverSolver(firstSolver, second.Solver(captchago.AntiCaptcha))
+
+	sol, err := f.RecaptchaV2(captchago.RecaptchaV2Options{PageURL: "https://example.com", SiteKey: "site-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.ReportBad(sol); err != nil {
+		t.Fatal(err)
+	}
+
+	if countPath(first, "/reportIncorrectRecaptcha") != 0 || countPath(second, "/reportIncorrectRecaptcha") != 1 {
+		t.Error("report wasn't sent to the backend that solved the task")
+	}
+}
+
+func TestFailoverGetBalance(t *testing.T) {
+	first, second := newServer(t), newServer(t)
+	first.SetBalance(1.5)
+	second.SetBalance(2)
+
+	f := captchago.NewFailoverSolver(first.Solver(captchago.AntiCaptcha), second.Solver(captchago.TwoCaptcha))
+
+	balance, err := f.GetBalanceContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if balance != 3.5 {
+		t.Errorf("balance %v, want 3.5", balance)
+	}
+}
+
+func TestFailoverSupports(t *testing.T) {
+	srv := newServer(t)
+
+	f := captchago.NewFailoverSolver(srv.Solver(captchago.TwoCaptcha))
+	if f.Supports(captchago.CaptchaKasada) {
+		t.Error("2captcha doesn't solve kasada")
+	}
+
+	f.Backends = append(f.Backends, srv.Solver(captchago.CapSolver))
+	if !f.Supports(captchago.CaptchaKasada) {
+		t.Error("capsolver solves kasada")
+	}
+}
@@ -0,0 +1,205 @@
+package captchago
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// hedgeSamples is the number of recent solve times kept to calculate the hedge delay
+const hedgeSamples = 100
+
+// NewHedgedSolver returns a solver that sends a captcha to the next solver whenever the current ones are taking too long
+func NewHedgedSolver(delay time.Duration, solvers ...*Solver) *HedgedSolver {
+	return &HedgedSolver{
+		Solvers: solvers,
+		Delay:   delay,
+	}
+}
+
+// Solve races the solvers, starting the next one every time the hedge delay passes or one fails.
+// Once a solution arrives every other task is cancelled
+func (h *HedgedSolver) Solve(ctx context.Context, o TaskOptions) (*HedgeResult, error) {
+	if len(h.Solvers) == 0 {
+		return nil, errors.New("hedged solver has no solvers")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type attempt struct {
+		index int
+		sol   *Solution
+		err   error
+		took  time.Duration
+	}
+
+	results := make(chan attempt, len(h.Solvers))
+	started, pending := 0, 0
+
+	startNext := func() {
+		i := started
+		started++
+		pending++
+
+		if i > 0 {
+			h.Solvers[i].log(LogLevelInfo, "hedging", "attempt", i+1, "type", o.captchaType())
+		}
+
+		go func() {
+			start := time.Now()
+			sol, err := h.Solvers[i].Solve(ctx, o)
+			results <- attempt{i, sol, err, time.Since(start)}
+		}()
+	}
+
+	delay := h.delay()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	startNext()
+
+	var result *HedgeResult
+	var lastErr error
+
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if started < len(h.Solvers) && result == nil {
+				startNext()
+				timer.Reset(delay)
+			}
+		case a := <-results:
+			pending--
+
+			// the first solver's time is recorded even when it loses, otherwise the percentile only
+			// sees its fast solves. When it was cancelled its time is a lower bound of how long it would have taken
+			if a.index == 0 && solveTimeKnown(a.err) {
+				h.record(a.took)
+			}
+
+			if a.err == nil && result == nil {
+				result = &HedgeResult{
+					Solution: a.sol,
+					Winner:   a.index,
+					Service:  h.Solvers[a.index].service,
+				}
+
+				// stop polling every other task, they are collected below to count what they cost
+				cancel()
+				continue
+			}
+
+			if result == nil {
+				lastErr = a.err
+
+				// don't wait for the delay when a solver has already failed
+				if started < len(h.Solvers) && ctx.Err() == nil {
+					startNext()
+					timer.Reset(delay)
+				}
+				continue
+			}
+
+			// a losing task only costs money if it was created
+			var canceled *CanceledError
+			if a.err == nil || (errors.As(a.err, &canceled) && canceled.TaskId != nil) {
+				result.DuplicateTasks++
+				result.DuplicateCost += h.Solvers[a.index].taskCost(o.captchaType(), a.sol)
+			}
+		}
+	}
+
+	if result == nil {
+		return nil, lastErr
+	}
+
+	result.Started = started
+	return result, nil
+}
+
+// solveTimeKnown reports whether a solve that returned err says something about how long the service takes.
+// Tasks that failed before they were created don't
+func solveTimeKnown(err error) bool {
+	if err == nil {
+		return true
+	}
+
+	var canceled *CanceledError
+	if errors.As(err, &canceled) {
+		return true
+	}
+
+	var serviceErr *ServiceError
+	return errors.As(err, &serviceErr) && serviceErr.TaskId != nil
+}
+
+// delay returns how long to wait before starting the next solver
+func (h *HedgedSolver) delay() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// a percentile means nothing with only a few samples
+	if h.Percentile <= 0 || len(h.latencies) < 10 {
+		return h.Delay
+	}
+
+	sorted := make([]time.Duration, len(h.latencies))
+	copy(sorted, h.latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	i := int(h.Percentile * float64(len(sorted)-1))
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+
+	return sorted[i]
+}
+
+// record stores how long the first solver took, or ran before it was cancelled
+func (h *HedgedSolver) record(took time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.latencies = append(h.latencies, took)
+	if len(h.latencies) > hedgeSamples {
+		h.latencies = h.latencies[len(h.latencies)-hedgeSamples:]
+	}
+}
+
+// HedgedSolver sends the same captcha to several solvers, using whichever answers first
+type HedgedSolver struct {
+	// Solvers are started in order, the first one straight away
+	Solvers []*Solver
+
+	// Delay is how long to wait for the running solvers before starting the next one
+	Delay time.Duration
+
+	// Percentile replaces Delay with that percentile of the first solver's recent solve times, like 0.9.
+	// Delay is still used until enough solves have been recorded
+	Percentile float64
+
+	mu        sync.Mutex
+	latencies []time.Duration
+}
+
+type HedgeResult struct {
+	Solution *Solution
+
+	// Winner is the index of the solver that solved the captcha
+	Winner int
+
+	// Service is the service of the winning solver
+	Service SolveService
+
+	// Started is how many solvers were started
+	Started int
+
+	// DuplicateTasks is how many losing tasks were created before they were cancelled
+	DuplicateTasks int
+
+	// DuplicateCost is what the losing tasks cost, estimated with Solver.Prices when the service doesn't report it
+	DuplicateCost float64
+}
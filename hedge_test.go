@@ -0,0 +1,70 @@
+package captchago_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/median/captchago"
+	"github.com/median/captchago/captchagotest"
+)
+
+func TestHedgeSlowSolver(t *testing.T) {
+	slow, fast := newServer(t), newServer(t)
+	slow.SetDefault(captchagotest.SolveAfter(1000))
+
+	h := captchago.NewHedgedSolver(time.Millisecond*20, slow.Solver(captchago.AntiCaptcha), fast.Solver(captchago.TwoCaptcha))
+
+	result, err := h.Solve(context.Background(), captchago.HCaptchaOptions{PageURL: "https://example.com", SiteKey: "site-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Winner != 1 || result.Service != captchago.TwoCaptcha || result.Started != 2 {
+		t.Errorf("result %+v", result)
+	}
+
+	// the slow task was created before it was cancelled
+	if result.DuplicateTasks != 1 {
+		t.Errorf("%d duplicate tasks, want 1", result.DuplicateTasks)
+	}
+}
+
+func TestHedgeFastSolver(t *testing.T) {
+	fast, unused := newServer(t), newServer(t)
+
+	h := captchago.NewHedgedSolver(time.Second*10, fast.Solver(captchago.AntiCaptcha), unused.Solver(captchago.AntiCaptcha))
+
+	result, err := h.Solve(context.Background(), captchago.HCaptchaOptions{PageURL: "https://example.com", SiteKey: "site-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Winner != 0 || result.Started != 1 || result.DuplicateTasks != 0 {
+		t.Errorf("result %+v", result)
+	}
+
+	if n := len(unused.Requests()); n != 0 {
+		t.Errorf("second solver got %d requests", n)
+	}
+}
+
+func TestHedgeFailureStartsNext(t *testing.T) {
+	failing, working := newServer(t), newServer(t)
+	failing.SetDefault(captchagotest.FailCreate("ERROR_ZERO_BALANCE"))
+
+	// the delay would time the test out, a failure starts the next solver straight away
+	h := captchago.NewHedgedSolver(time.Minute, failing.Solver(captchago.AntiCaptcha), working.Solver(captchago.AntiCaptcha))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	result, err := h.Solve(ctx, captchago.HCaptchaOptions{PageURL: "https://example.com", SiteKey: "site-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Winner != 1 {
+		t.Errorf("winner %d, want 1", result.Winner)
+	}
+}
@@ -0,0 +1,160 @@
+package captchago
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	LogLevelDebug LogLevel = iota
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+)
+
+// NopLogger discards every event
+var NopLogger Logger = nopLogger{}
+
+// verboseLogger is used by every solver with Verbose set and no Logger
+var verboseLogger = NewStdLogger(log.New(os.Stdout, "captchago ", log.LstdFlags), LogLevelDebug)
+
+// Logger receives structured events from the solver.
+// keyvals are alternating keys and values, like "task_id", 123, "elapsed", time.Second
+type Logger interface {
+	Log(level LogLevel, msg string, keyvals ...any)
+}
+
+// NewStdLogger returns a Logger that writes events at or above level to l, log.Default() is used if l is nil
+func NewStdLogger(l *log.Logger, level LogLevel) Logger {
+	if l == nil {
+		l = log.Default()
+	}
+
+	return &StdLogger{
+		Logger: l,
+		Level:  level,
+	}
+}
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelDebug:
+		return "DEBUG"
+	case LogLevelInfo:
+		return "INFO"
+	case LogLevelWarn:
+		return "WARN"
+	case LogLevelError:
+		return "ERROR"
+	}
+
+	return fmt.Sprintf("LEVEL(%d)", int(l))
+}
+
+func (l *StdLogger) Log(level LogLevel, msg string, keyvals ...any) {
+	if level < l.Level {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("[" + level.String() + "] " + msg)
+
+	for i := 0; i < len(keyvals); i += 2 {
+		b.WriteString(" " + fmt.Sprint(keyvals[i]) + "=")
+
+		if i+1 < len(keyvals) {
+			b.WriteString(fmt.Sprint(keyvals[i+1]))
+		}
+	}
+
+	l.Logger.Print(b.String())
+}
+
+func (nopLogger) Log(LogLevel, string, ...any) {}
+
+// logger returns the logger the solver should use, nil if nothing should be logged
+func (s *Solver) logger() Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+
+	if s.Verbose {
+		return verboseLogger
+	}
+
+	return nil
+}
+
+// log sends an event to the solver's logger, redacting secrets unless LogSecrets is enabled
+func (s *Solver) log(level LogLevel, msg string, keyvals ...any) {
+	l := s.logger()
+	if l == nil {
+		return
+	}
+
+	// keyvals is copied, redacting in place would change the caller's slice
+	fields := make([]any, 0, len(keyvals)+2)
+	fields = append(fields, "service", s.service)
+	for i, v := range keyvals {
+		if i%2 == 1 && !s.LogSecrets {
+			v = s.redact(v)
+		}
+		fields = append(fields, v)
+	}
+
+	l.Log(level, msg, fields...)
+}
+
+var (
+	// userinfoPattern matches user:password@ in proxies and urls
+	userinfoPattern = regexp.MustCompile(`([^\s/:@=&]+):([^\s/@]+)@`)
+
+	// escapedUserinfoPattern matches user%3Apassword%40 in query strings
+	escapedUserinfoPattern = regexp.MustCompile(`(?i)%3A([^\s%&]+)%40`)
+)
+
+// redact removes the api key and proxy passwords from a log value
+func (s *Solver) redact(v any) any {
+	var str string
+
+	switch t := v.(type) {
+	case *Proxy:
+		if t == nil || t.login == nil {
+			return t.FullString()
+		}
+
+		return t.pType + "://" + t.login.Username + ":[REDACTED]@" + t.address + ":" + fmt.Sprint(t.port)
+	case string:
+		str = t
+	case error:
+		str = t.Error()
+	case fmt.Stringer:
+		str = t.String()
+	default:
+		return v
+	}
+
+	if s.ApiKey != "" {
+		str = strings.ReplaceAll(str, s.ApiKey, "[REDACTED]")
+	}
+
+	str = userinfoPattern.ReplaceAllString(str, "$1:[REDACTED]@")
+	str = escapedUserinfoPattern.ReplaceAllString(str, "%3A[REDACTED]%40")
+
+	return str
+}
+
+// StdLogger writes events to a standard library *log.Logger as "[LEVEL] msg key=value ..."
+type StdLogger struct {
+	Logger *log.Logger
+
+	// Level is the lowest level that is written
+	Level LogLevel
+}
+
+type nopLogger struct{}
+
+type LogLevel int
@@ -0,0 +1,182 @@
+package captchago
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"time"
+)
+
+// DefaultRetryPolicy is used when Solver.RetryPolicy is nil
+var DefaultRetryPolicy = RetryPolicy{
+	CreateAttempts:  3,
+	MaxPollFailures: 5,
+	BaseDelay:       time.Second,
+	MaxDelay:        time.Second * 30,
+	Jitter:          0.2,
+}
+
+// RetryPolicy controls how failed requests are retried. It is used the same way for every service.
+type RetryPolicy struct {
+	// CreateAttempts is the max number of times a task is submitted, anything below 1 is treated as 1
+	CreateAttempts int
+
+	// MaxPollFailures is the max number of consecutive failed result requests before the solve is aborted.
+	// 0 aborts on the first failure, a negative value keeps polling forever
+	MaxPollFailures int
+
+	// BaseDelay is the delay before the first retry, it doubles with every following retry
+	BaseDelay time.Duration
+
+	// MaxDelay caps the delay between retries, 0 means no cap
+	MaxDelay time.Duration
+
+	// Jitter is the fraction of the delay that is randomised, between 0 and 1
+	Jitter float64
+
+	// ShouldRetry decides if an error is worth retrying, if nil transport errors and
+	// errors classified as retryable (see IsRetryable) are retried, see RetryAmbiguous for task creation.
+	// Cancelled solves, open circuit breakers and exceeded budgets are never retried.
+	ShouldRetry func(err error) bool
+
+	// RetryAmbiguous also retries task creation after transport errors that may have happened after the
+	// service received the task, like timeouts and reset connections. The task may then be created and paid twice.
+	// By default creation is only retried when the request never reached the service, or the service refused it
+	RetryAmbiguous bool
+}
+
+// retryPolicy returns the policy the solver should use
+func (s *Solver) retryPolicy() RetryPolicy {
+	if s.RetryPolicy != nil {
+		return *s.RetryPolicy
+	}
+
+	return DefaultRetryPolicy
+}
+
+// backoff returns how long to wait before the given retry, starting at 1
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	// without a cap the doubling stops before it overflows, leaving room for the jitter
+	d := p.BaseDelay
+	for i := 1; i < retry && (p.MaxDelay == 0 || d < p.MaxDelay) && d <= math.MaxInt64/4; i++ {
+		d *= 2
+	}
+
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+
+	if p.Jitter > 0 && d > 0 {
+		// spread the delay evenly around d
+		spread := float64(d) * p.Jitter
+		d += time.Duration(spread * (rand.Float64()*2 - 1))
+	}
+
+	return d
+}
+
+func (p RetryPolicy) shouldRetry(err error) bool {
+	var canceled *CanceledError
+	if errors.As(err, &canceled) || errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrBudgetExceeded) {
+		return false
+	}
+
+	if p.ShouldRetry != nil {
+		return p.ShouldRetry(err)
+	}
+
+	// the service answered, but not with anything usable
+	if errors.Is(err, ErrMalformedResponse) {
+		return false
+	}
+
+	var serviceErr *ServiceError
+	if errors.As(err, &serviceErr) {
+		return serviceErr.Retryable()
+	}
+
+	// anything else is a transport or decoding error
+	return true
+}
+
+// shouldRetryCreate reports whether creating a task should be attempted again after err
+func (p RetryPolicy) shouldRetryCreate(err error) bool {
+	if !p.shouldRetry(err) {
+		return false
+	}
+
+	if p.ShouldRetry != nil || p.RetryAmbiguous {
+		return true
+	}
+
+	var serviceErr *ServiceError
+	if errors.As(err, &serviceErr) {
+		return true
+	}
+
+	return notSent(err)
+}
+
+// notSent reports whether err happened before the request was sent, so the service can't have created a task
+func notSent(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// retryPoll reports whether polling should continue after err.
+// failures is the number of consecutive failures including this one
+func (p RetryPolicy) retryPoll(err error, failures int) bool {
+	if p.MaxPollFailures >= 0 && failures > p.MaxPollFailures {
+		return false
+	}
+
+	// the task itself failed, asking again won't change the answer
+	if errors.Is(err, ErrUnsolvable) {
+		return false
+	}
+
+	return p.shouldRetry(err)
+}
+
+// retryCreate calls create until it succeeds, returns an error that shouldn't be retried or runs out of attempts
+func retryCreate[T any](ctx context.Context, p RetryPolicy, create func() (T, error)) (T, error) {
+	attempts := p.CreateAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		r, err := create()
+		if err == nil || attempt >= attempts || !p.shouldRetryCreate(err) {
+			return r, err
+		}
+
+		if err := sleepContext(ctx, p.backoff(attempt)); err != nil {
+			return r, contextError(ctx, nil, err)
+		}
+	}
+}
+
+// pollFailed is called when a result request fails. It returns nil once the backoff has passed
+// if polling should continue, otherwise it returns the error the solve should fail with
+func (s *Solver) pollFailed(ctx context.Context, p RetryPolicy, taskId any, err error, failures int) error {
+	err = contextError(ctx, taskId, err)
+	if !p.retryPoll(err, failures) {
+		return err
+	}
+
+	s.log(LogLevelWarn, "poll failed", "task_id", taskId, "error", err, "failures", failures)
+
+	if err := sleepContext(ctx, p.backoff(failures)); err != nil {
+		return contextError(ctx, taskId, err)
+	}
+
+	return nil
+}
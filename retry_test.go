@@ -0,0 +1,173 @@
+package captchago_test
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/median/captchago"
+	"github.com/median/captchago/captchagotest"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// failingCreates returns a transport that fails the first n task creations with err
+func failingCreates(n int, err error, attempts *int) http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/createTask" {
+			*attempts++
+			if *attempts <= n {
+				return nil, err
+			}
+		}
+		return http.DefaultTransport.RoundTrip(req)
+	})
+}
+
+func TestRetryCreate(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	tests := []struct {
+		name      string
+		err       error
+		ambiguous bool
+		attempts  int
+		solved    bool
+	}{
+		{"dial error", dialErr, false, 2, true},
+		{"dns error", &net.DNSError{Err: "no such host", Name: "example.com"}, false, 2, true},
+		{"reset after sending", io.ErrUnexpectedEOF, false, 1, false},
+		{"reset after sending, ambiguous allowed", io.ErrUnexpectedEOF, true, 2, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := newServer(t)
+			solver := srv.Solver(captchago.AntiCaptcha)
+			solver.RetryPolicy.RetryAmbiguous = test.ambiguous
+
+			attempts := 0
+			solver.HTTPClient = &http.Client{Transport: failingCreates(1, test.err, &attempts)}
+
+			_, err := solver.RecaptchaV2(captchago.RecaptchaV2Options{PageURL: "https://example.com", SiteKey: "site-key"})
+			if (err == nil) != test.solved {
+				t.Fatalf("got %v, solved should be %v", err, test.solved)
+			}
+
+			if attempts != test.attempts {
+				t.Errorf("%d attempts, want %d", attempts, test.attempts)
+			}
+		})
+	}
+}
+
+func TestRetryCreateServiceErrors(t *testing.T) {
+	srv := newServer(t)
+	srv.Script(captchago.CaptchaRecaptchaV2, captchagotest.FailCreate("ERROR_NO_SLOT_AVAILABLE"), captchagotest.FailCreate("ERROR_NO_SLOT_AVAILABLE"))
+	srv.Script(captchago.CaptchaHCaptcha, captchagotest.FailCreate("ERROR_ZERO_BALANCE"))
+
+	solver := srv.Solver(captchago.AntiCaptcha)
+
+	if _, err := solver.RecaptchaV2(captchago.RecaptchaV2Options{PageURL: "https://example.com", SiteKey: "site-key"}); err != nil {
+		t.Fatalf("no slot wasn't retried: %v", err)
+	}
+
+	if _, err := solver.HCaptcha(captchago.HCaptchaOptions{PageURL: "https://example.com", SiteKey: "site-key"}); !errors.Is(err, captchago.ErrZeroBalance) {
+		t.Fatalf("got %v, want ErrZeroBalance", err)
+	}
+
+	if n := len(srv.Tasks()); n != 4 {
+		t.Errorf("%d task requests, want 4", n)
+	}
+}
+
+// badGateway returns a transport that answers the first n requests to path with a 502 page
+func badGateway(path string, n int, requests *int) http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == path {
+			*requests++
+			if *requests <= n {
+				return &http.Response{
+					StatusCode: http.StatusBadGateway,
+					Body:       io.NopCloser(strings.NewReader("<html><body>502 Bad Gateway</body></html>")),
+					Request:    req,
+				}, nil
+			}
+		}
+		return http.DefaultTransport.RoundTrip(req)
+	})
+}
+
+func TestRetryServerError(t *testing.T) {
+	task := captchago.HCaptchaOptions{PageURL: "https://example.com", SiteKey: "site-key"}
+
+	for service, paths := range map[captchago.SolveService][2]string{
+		captchago.AntiCaptcha: {"/createTask", "/getTaskResult"},
+		captchago.TwoCaptcha:  {"/in.php", "/res.php"},
+	} {
+		t.Run(service, func(t *testing.T) {
+			srv := newServer(t)
+			solver := srv.Solver(service)
+
+			// a gateway error while polling is retried
+			polls := 0
+			solver.HTTPClient = &http.Client{Transport: badGateway(paths[1], 1, &polls)}
+
+			if _, err := solver.HCaptcha(task); err != nil {
+				t.Fatalf("poll wasn't retried: %v", err)
+			}
+
+			// the task may have been created behind the gateway
+			creates := 0
+			solver.HTTPClient = &http.Client{Transport: badGateway(paths[0], 1, &creates)}
+
+			_, err := solver.HCaptcha(task)
+
+			var statusErr *captchago.StatusError
+			if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadGateway {
+				t.Fatalf("got %v, want a 502 *StatusError", err)
+			}
+
+			if creates != 1 {
+				t.Errorf("%d creates, want 1", creates)
+			}
+
+			solver.RetryPolicy.RetryAmbiguous = true
+			creates = 0
+
+			if _, err := solver.HCaptcha(task); err != nil {
+				t.Fatalf("create wasn't retried with RetryAmbiguous: %v", err)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	capped := captchago.RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Second * 30}
+	for retry, want := range map[int]time.Duration{1: time.Second, 2: time.Second * 2, 4: time.Second * 8, 6: time.Second * 30, 100: time.Second * 30} {
+		if d := capped.Backoff(retry); d != want {
+			t.Errorf("retry %d: %v, want %v", retry, d, want)
+		}
+	}
+
+	// without a cap the delay grows until it saturates, it never overflows
+	uncapped := captchago.RetryPolicy{BaseDelay: time.Second}
+	for retry := 2; retry <= 1000; retry++ {
+		if d, last := uncapped.Backoff(retry), uncapped.Backoff(retry-1); d < last {
+			t.Fatalf("retry %d: delay dropped from %v to %v", retry, last, d)
+		}
+	}
+
+	uncapped.Jitter = 1
+	if d := uncapped.Backoff(1000); d < 0 {
+		t.Fatalf("delay overflowed to %v", d)
+	}
+}
@@ -0,0 +1,360 @@
+package captchago
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// RouteOrdered tries the backends in the order they are listed
+	RouteOrdered RouteStrategy = "ordered"
+
+	// RouteCheapest tries the backends from cheapest to most expensive, using Solver.Prices
+	RouteCheapest RouteStrategy = "cheapest"
+
+	// RouteAdaptive tries the backends with the best recent success rate and p90 latency first, see Solver.Stats.
+	// Backends with too few recent solves to judge are tried first, in the order they are listed
+	RouteAdaptive RouteStrategy = "adaptive"
+)
+
+// adaptiveMinSamples is the number of recent solves needed before RouteAdaptive ranks a backend
+const adaptiveMinSamples = 5
+
+// LoadRouterConfig reads a json router config, like:
+//
+//	{
+//		"rules": [
+//			{"name": "anti-bot", "types": ["kasada", "akamai"], "backends": ["capsolver"]},
+//			{"name": "images", "types": ["image"], "backends": ["2captcha"]},
+//			{"name": "hcaptcha", "types": ["hcaptcha"], "backends": ["capsolver", "2captcha"], "strategy": "cheapest"}
+//		],
+//		"default": ["capsolver", "2captcha"]
+//	}
+func LoadRouterConfig(r io.Reader) (RouterConfig, error) {
+	var config RouterConfig
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&config); err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
+// NewRouter returns a router that sends every task to the backends of the first rule matching it.
+// backends maps the names used in the config to their solvers
+func NewRouter(backends map[string]*Solver, config RouterConfig) (*Router, error) {
+	check := func(names []string) error {
+		for _, name := range names {
+			if _, ok := backends[name]; !ok {
+				return fmt.Errorf("unknown backend %q", name)
+			}
+		}
+		return nil
+	}
+
+	for i, rule := range config.Rules {
+		if len(rule.Backends) == 0 {
+			return nil, fmt.Errorf("rule %d has no backends", i)
+		}
+
+		if err := check(rule.Backends); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+
+		switch rule.Strategy {
+		case "", RouteOrdered, RouteCheapest, RouteAdaptive:
+		default:
+			return nil, fmt.Errorf("rule %d: unknown strategy %q", i, rule.Strategy)
+		}
+	}
+
+	if err := check(config.Default); err != nil {
+		return nil, fmt.Errorf("default: %w", err)
+	}
+
+	return &Router{
+		backends: backends,
+		config:   config,
+	}, nil
+}
+
+// Route returns which backends the task would be sent to, in the order they would be tried.
+// Backends that don't support the captcha type are left out
+func (r *Router) Route(o TaskOptions) (*RouteDecision, error) {
+	info := describeTask(o)
+
+	decision := &RouteDecision{
+		Type: info.captchaType,
+		Host: info.host,
+	}
+
+	names := r.config.Default
+	strategy := RouteOrdered
+
+	for i, rule := range r.config.Rules {
+		if rule.matches(info) {
+			decision.Rule = rule.Name
+			if decision.Rule == "" {
+				decision.Rule = fmt.Sprintf("#%d", i)
+			}
+
+			names = rule.Backends
+			if rule.Strategy != "" {
+				strategy = rule.Strategy
+			}
+			break
+		}
+	}
+
+	decision.Strategy = strategy
+
+	for _, name := range names {
+		if r.backends[name].Supports(info.captchaType) {
+			decision.Backends = append(decision.Backends, name)
+		}
+	}
+
+	if strategy == RouteCheapest {
+		price := func(name string) float64 {
+			p, ok := r.backends[name].Prices[info.captchaType]
+			if !ok {
+				return math.Inf(1)
+			}
+			return p
+		}
+
+		sort.SliceStable(decision.Backends, func(i, j int) bool {
+			return price(decision.Backends[i]) < price(decision.Backends[j])
+		})
+	}
+
+	if strategy == RouteAdaptive {
+		scores := make(map[string]float64, len(decision.Backends))
+		for _, name := range decision.Backends {
+			st := r.backends[name].recentStats(info.captchaType)
+			if st.Recent < adaptiveMinSamples {
+				scores[name] = math.Inf(1)
+				continue
+			}
+			// a backend taking 30s at p90 is worth half of one answering instantly
+			scores[name] = st.RecentSuccessRate / (1 + st.RecentP90.Seconds()/30)
+		}
+
+		sort.SliceStable(decision.Backends, func(i, j int) bool {
+			return scores[decision.Backends[i]] > scores[decision.Backends[j]]
+		})
+	}
+
+	if len(decision.Backends) == 0 {
+		return decision, &UnsupportedError{Method: "routing " + info.captchaType + " tasks"}
+	}
+
+	return decision, nil
+}
+
+// Solve sends the task to the routed backends, moving on to the next one like FailoverSolver does
+func (r *Router) Solve(ctx context.Context, o TaskOptions) (*Solution, error) {
+	decision, err := r.Route(o)
+	r.logDecision(decision, err)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &FailoverSolver{
+		Timeout:        r.Timeout,
+		ShouldFailover: r.ShouldFailover,
+	}
+
+	for _, name := range decision.Backends {
+		f.Backends = append(f.Backends, r.backends[name])
+	}
+
+	return f.Solve(ctx, o)
+}
+
+func (r *Router) SolveBatch(ctx context.Context, tasks []TaskOptions, o BatchOptions) []BatchResult {
+	return solveBatch(ctx, tasks, o, r.Solve)
+}
+
+func (r *Router) logDecision(decision *RouteDecision, err error) {
+	if r.Logger == nil {
+		return
+	}
+
+	if err != nil {
+		r.Logger.Log(LogLevelError, "no route", "type", decision.Type, "host", decision.Host, "rule", decision.Rule, "error", err)
+		return
+	}
+
+	r.Logger.Log(LogLevelInfo, "routed task", "type", decision.Type, "host", decision.Host, "rule", decision.Rule,
+		"strategy", decision.Strategy, "backends", strings.Join(decision.Backends, ","))
+}
+
+func (rule RouteRule) matches(info taskInfo) bool {
+	if len(rule.Types) > 0 && !containsString(rule.Types, info.captchaType) {
+		return false
+	}
+
+	if len(rule.Hosts) > 0 {
+		matched := false
+		for _, host := range rule.Hosts {
+			// *.example.com matches every subdomain of example.com
+			if strings.HasPrefix(host, "*.") && strings.HasSuffix(info.host, host[1:]) || host == info.host {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	if rule.Enterprise != nil && *rule.Enterprise != info.enterprise {
+		return false
+	}
+
+	if rule.Proxy != nil && *rule.Proxy != info.proxy {
+		return false
+	}
+
+	if rule.Challenge != nil && *rule.Challenge != info.challenge {
+		return false
+	}
+
+	return true
+}
+
+// describeTask returns the fields of o that rules can match on
+func describeTask(o TaskOptions) taskInfo {
+	info := taskInfo{
+		captchaType: o.captchaType(),
+	}
+
+	var pageURL string
+	var proxy *Proxy
+
+	switch o := o.(type) {
+	case RecaptchaV2Options:
+		pageURL, proxy, info.enterprise = o.PageURL, o.Proxy, o.Enterprise != nil
+	case RecaptchaV3Options:
+		pageURL, info.enterprise = o.PageURL, o.Enterprise
+	case HCaptchaOptions:
+		pageURL, proxy, info.enterprise = o.PageURL, o.Proxy, o.EnterprisePayload != nil
+	case FunCaptchaOptions:
+		pageURL, proxy = o.PageURL, o.Proxy
+	case CloudflareOptions:
+		pageURL, proxy, info.challenge = o.PageURL, o.Proxy, o.Type == CloudflareTypeChallenge
+	case GeeTestOptions:
+		pageURL, proxy = o.PageURL, o.Proxy
+	case GeeTestV4Options:
+		pageURL, proxy = o.PageURL, o.Proxy
+	case AmazonWAFOptions:
+		pageURL, proxy = o.PageURL, o.Proxy
+	case DataDomeOptions:
+		pageURL, proxy = o.PageURL, o.Proxy
+	case AkamaiOptions:
+		pageURL, proxy = o.PageURL, o.Proxy
+	case KasadaOptions:
+		pageURL, proxy = o.PageURL, o.Proxy
+	}
+
+	info.proxy = proxy != nil
+
+	if parsed, err := url.Parse(pageURL); err == nil {
+		info.host = strings.ToLower(parsed.Hostname())
+	}
+
+	return info
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Router picks the backends for every task from a list of rules, see NewRouter
+type Router struct {
+	// Logger receives every routing decision, nothing is logged if nil
+	Logger Logger
+
+	// Timeout and ShouldFailover are used like in FailoverSolver when trying the routed backends
+	Timeout        time.Duration
+	ShouldFailover func(err error) bool
+
+	backends map[string]*Solver
+	config   RouterConfig
+}
+
+type RouterConfig struct {
+	// Rules are checked in order, the first matching rule is used
+	Rules []RouteRule `json:"rules"`
+
+	// Default are the backends used when no rule matches
+	Default []string `json:"default"`
+}
+
+// RouteRule matches a task when every field that is set matches
+type RouteRule struct {
+	// Name is shown in the logs to tell which rule was used
+	Name string `json:"name,omitempty"`
+
+	// Types are the captcha types the rule applies to, like "hcaptcha"
+	Types []CaptchaType `json:"types,omitempty"`
+
+	// Hosts are the page url hosts the rule applies to, "*.example.com" matches every subdomain
+	Hosts []string `json:"hosts,omitempty"`
+
+	// Enterprise matches enterprise recaptcha and hcaptcha tasks
+	Enterprise *bool `json:"enterprise,omitempty"`
+
+	// Proxy matches tasks that do or don't have a proxy
+	Proxy *bool `json:"proxy,omitempty"`
+
+	// Challenge matches cloudflare challenges, as opposed to turnstile
+	Challenge *bool `json:"challenge,omitempty"`
+
+	// Backends are the names of the backends to use
+	Backends []string `json:"backends"`
+
+	// Strategy decides the order the backends are tried in, RouteOrdered if empty
+	Strategy RouteStrategy `json:"strategy,omitempty"`
+}
+
+// RouteDecision explains where a task was sent and why
+type RouteDecision struct {
+	Type CaptchaType
+	Host string
+
+	// Rule is the name of the matching rule, "" if the default backends were used
+	Rule     string
+	Strategy RouteStrategy
+
+	// Backends are the names of the backends in the order they are tried
+	Backends []string
+}
+
+type taskInfo struct {
+	captchaType CaptchaType
+	host        string
+	enterprise  bool
+	proxy       bool
+	challenge   bool
+}
+
+type RouteStrategy = string
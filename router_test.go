@@ -0,0 +1,140 @@
+package captchago_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/median/captchago"
+	"github.com/median/captchago/captchagotest"
+)
+
+const testRouterConfig = `{
+	"rules": [
+		{"name": "anti-bot", "types": ["kasada", "akamai"], "backends": ["capsolver"]},
+		{"name": "example", "hosts": ["*.example.com"], "backends": ["2captcha", "capsolver"]},
+		{"name": "hcaptcha", "types": ["hcaptcha"], "backends": ["2captcha", "capsolver"], "strategy": "cheapest"}
+	],
+	"default": ["capsolver", "2captcha"]
+}`
+
+func newTestRouter(t *testing.T) (*captchago.Router, map[string]*captchagotest.Server) {
+	t.Helper()
+
+	config, err := captchago.LoadRouterConfig(strings.NewReader(testRouterConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	servers := map[string]*captchagotest.Server{
+		"capsolver": newServer(t),
+		"2captcha":  newServer(t),
+	}
+
+	backends := map[string]*captchago.Solver{
+		"capsolver": servers["capsolver"].Solver(captchago.CapSolver),
+		"2captcha":  servers["2captcha"].Solver(captchago.TwoCaptcha),
+	}
+	backends["capsolver"].Prices = map[captchago.CaptchaType]float64{captchago.CaptchaHCaptcha: 0.001}
+	backends["2captcha"].Prices = map[captchago.CaptchaType]float64{captchago.CaptchaHCaptcha: 0.003}
+
+	r, err := captchago.NewRouter(backends, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r, servers
+}
+
+func TestRoute(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	tests := []struct {
+		task     captchago.TaskOptions
+		rule     string
+		backends []string
+	}{
+		{captchago.KasadaOptions{PageURL: "https://shop.example.com", Proxy: testProxy}, "anti-bot", []string{"capsolver"}},
+		{captchago.RecaptchaV2Options{PageURL: "https://www.example.com/login", SiteKey: "site-key"}, "example", []string{"2captcha", "capsolver"}},
+		{captchago.HCaptchaOptions{PageURL: "https://other.com", SiteKey: "site-key"}, "hcaptcha", []string{"capsolver", "2captcha"}},
+		{captchago.RecaptchaV2Options{PageURL: "https://other.com", SiteKey: "site-key"}, "", []string{"capsolver", "2captcha"}},
+	}
+
+	for _, test := range tests {
+		decision, err := r.Route(test.task)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if decision.Rule != test.rule || !reflect.DeepEqual(decision.Backends, test.backends) {
+			t.Errorf("%s on %s: rule %q backends %v, want %q %v", decision.Type, decision.Host, decision.Rule, decision.Backends, test.rule, test.backends)
+		}
+	}
+}
+
+func TestRouterSolve(t *testing.T) {
+	r, servers := newTestRouter(t)
+	servers["capsolver"].SetDefault(captchagotest.FailCreate("ERROR_ZERO_BALANCE"))
+
+	sol, err := r.Solve(context.Background(), captchago.HCaptchaOptions{PageURL: "https://other.com", SiteKey: "site-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sol.Service != captchago.TwoCaptcha {
+		t.Errorf("solved by %s, want 2captcha after capsolver failed", sol.Service)
+	}
+}
+
+func TestRouterAdaptive(t *testing.T) {
+	config := captchago.RouterConfig{
+		Rules: []captchago.RouteRule{{Backends: []string{"first", "second"}, Strategy: captchago.RouteAdaptive}},
+	}
+
+	first, second := newServer(t), newServer(t)
+	first.SetDefault(captchagotest.FailWith(0, "ERROR_CAPTCHA_UNSOLVABLE"))
+
+	firstSolver := first.Solver(captchago.AntiCaptcha)
+	firstSolver.RetryPolicy.CreateAttempts = 1
+
+	secondSolver := second.Solver(captchago.AntiCaptcha)
+
+	r, err := captchago.NewRouter(map[string]*captchago.Solver{"first": firstSolver, "second": secondSolver}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task := captchago.HCaptchaOptions{PageURL: "https://example.com", SiteKey: "site-key"}
+
+	// unsolvable doesn't fail over, every task goes to the first backend and fails
+	for i := 0; i < 5; i++ {
+		if _, err := r.Solve(context.Background(), task); err != nil && !errors.Is(err, captchago.ErrUnsolvable) {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := secondSolver.HCaptcha(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	decision, err := r.Route(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(decision.Backends, []string{"second", "first"}) {
+		t.Errorf("backends %v, want the failing one last", decision.Backends)
+	}
+}
+
+func TestNewRouterUnknownBackend(t *testing.T) {
+	config := captchago.RouterConfig{Default: []string{"missing"}}
+
+	if _, err := captchago.NewRouter(map[string]*captchago.Solver{}, config); err == nil {
+		t.Fatal("unknown backend accepted")
+	}
+}
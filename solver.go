@@ -2,6 +2,8 @@ package captchago
 
 import (
 	"errors"
+	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -16,9 +18,9 @@ const (
 
 func New(service SolveService, apiKey string) (*Solver, error) {
 	solver := &Solver{
-		Verbose:     true,
 		ApiKey:      apiKey,
 		UpdateDelay: time.Second * 2,
+		stats:       newStatsCollector(),
 	}
 
 	err := solver.SetService(service)
@@ -71,6 +73,26 @@ func (s *Solver) GetService() SolveService {
 	return s.service
 }
 
+// taskCost returns what a task cost, from the solution if the service reported it, otherwise from Prices
+func (s *Solver) taskCost(captchaType CaptchaType, sol *Solution) float64 {
+	if sol != nil && sol.Cost != "" {
+		if cost, err := strconv.ParseFloat(sol.Cost, 64); err == nil {
+			return cost
+		}
+	}
+
+	return s.Prices[captchaType]
+}
+
+// httpClient returns the client used for all requests to the service
+func (s *Solver) httpClient() *http.Client {
+	if s.HTTPClient != nil {
+		return s.HTTPClient
+	}
+
+	return http.DefaultClient
+}
+
 // formatService formats the service name to reduce the chance of human errors
 func formatService(s SolveService) SolveService {
 	// remove spaces, dashes, and tlds
@@ -92,9 +114,17 @@ type Solver struct {
 	// UpdateDelay is the delay between each update getTaskResult request
 	UpdateDelay time.Duration
 
-	// Disabling Verbose will disable all logging
+	// Logger receives structured events about every task, nothing is logged if nil
+	Logger Logger
+
+	// Verbose logs every event to stdout when Logger is nil.
+	//
+	// Deprecated: set Logger instead, for example NewStdLogger(nil, LogLevelDebug)
 	Verbose bool
 
+	// LogSecrets disables redacting the api key and proxy passwords from logged events
+	LogSecrets bool
+
 	// ApiKey is the key used to authenticate with the service
 	ApiKey string
 
@@ -103,11 +133,31 @@ type Solver struct {
 	// they want as long as they share the same api methods.
 	ForcedDomain string
 
+	// HTTPClient is used for every request sent to the service, http.DefaultClient is used if nil.
+	// Set this to configure timeouts, TLS, proxies or a custom http.RoundTripper.
+	HTTPClient *http.Client
+
+	// Prices is the cost of one task per captcha type, used when the service doesn't report the cost (like 2captcha)
+	Prices map[CaptchaType]float64
+
+	// RetryPolicy controls how failed requests are retried, DefaultRetryPolicy is used if nil
+	RetryPolicy *RetryPolicy
+
+	// CircuitBreaker stops creating tasks on an endpoint that keeps failing, disabled if nil.
+	// Set it to &DefaultCircuitBreaker for sensible defaults
+	CircuitBreaker *CircuitBreaker
+
+	// Budget caps what the solver may spend, no limits apply if nil
+	Budget *Budget
+
 	// service is the service that the solver is using. It's private because it's only used internally
 	service SolveService
 
 	// methods is the methods used to solve captchas. It's private because it's only used internally
 	methods *solveMethods
+
+	// stats records every finished solve, see Stats
+	stats *statsCollector
 }
 
 type SolveService = string
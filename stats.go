@@ -0,0 +1,273 @@
+package captchago
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// statsSamples is how many latencies are kept per service and captcha type for the percentiles
+	statsSamples = 1000
+
+	// StatsWindow is how far back the recent success rate and latency of Stats look
+	StatsWindow = time.Minute * 15
+
+	// statsWindowSamples caps the number of solves in the sliding window
+	statsWindowSamples = 100
+)
+
+// Stats sums up the solves of one service and captcha type since the solver was created
+type Stats struct {
+	Service SolveService
+	Type    CaptchaType
+
+	Successes int
+	Failures  int
+
+	// Errors counts the failures by class, like "unsolvable", "zero_balance" or "transport"
+	Errors map[string]int
+
+	// TotalCost is the cost of every successful solve, see Solver.Prices
+	TotalCost float64
+
+	// P50, P90 and P99 are latency percentiles of the last solves, successful or not
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+
+	// Recent is the number of solves in the sliding window (the last StatsWindow, at most 100 solves)
+	Recent int
+
+	// RecentSuccessRate is the fraction of the solves in the sliding window that succeeded, between 0 and 1
+	RecentSuccessRate float64
+
+	// RecentP90 is the 90th latency percentile of the solves in the sliding window
+	RecentP90 time.Duration
+}
+
+// SuccessRate is the fraction of every solve that succeeded, between 0 and 1
+func (s Stats) SuccessRate() float64 {
+	total := s.Successes + s.Failures
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Successes) / float64(total)
+}
+
+// Stats returns the solve stats for every service and captcha type this solver has solved, sorted by type
+func (s *Solver) Stats() []Stats {
+	return s.stats.snapshot()
+}
+
+// recentStats returns the stats of the current service for a captcha type
+func (s *Solver) recentStats(captchaType CaptchaType) Stats {
+	for _, st := range s.Stats() {
+		if st.Service == s.service && st.Type == captchaType {
+			return st
+		}
+	}
+
+	return Stats{Service: s.service, Type: captchaType}
+}
+
+type statsKey struct {
+	service     SolveService
+	captchaType CaptchaType
+}
+
+type statsSample struct {
+	at      time.Time
+	latency time.Duration
+	ok      bool
+}
+
+type statsEntry struct {
+	successes int
+	failures  int
+	errors    map[string]int
+	cost      float64
+
+	// latencies is a ring buffer of the last statsSamples latencies
+	latencies []time.Duration
+	next      int
+
+	// window holds the solves in the sliding window, oldest first
+	window []statsSample
+}
+
+type statsCollector struct {
+	mu      sync.Mutex
+	entries map[statsKey]*statsEntry
+}
+
+func newStatsCollector() *statsCollector {
+	return &statsCollector{entries: map[statsKey]*statsEntry{}}
+}
+
+// record adds a finished solve, c may be nil for solvers not made with New
+func (c *statsCollector) record(service SolveService, captchaType CaptchaType, latency time.Duration, cost float64, err error) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := statsKey{service, captchaType}
+	entry := c.entries[key]
+	if entry == nil {
+		entry = &statsEntry{errors: map[string]int{}}
+		c.entries[key] = entry
+	}
+
+	if err != nil {
+		entry.failures++
+		entry.errors[errorClass(err)]++
+	} else {
+		entry.successes++
+		entry.cost += cost
+	}
+
+	if len(entry.latencies) < statsSamples {
+		entry.latencies = append(entry.latencies, latency)
+	} else {
+		entry.latencies[entry.next] = latency
+		entry.next = (entry.next + 1) % statsSamples
+	}
+
+	now := time.Now()
+	entry.window = append(entry.window, statsSample{at: now, latency: latency, ok: err == nil})
+	entry.trim(now)
+}
+
+// trim drops the solves that fell out of the sliding window
+func (e *statsEntry) trim(now time.Time) {
+	drop := 0
+	if len(e.window) > statsWindowSamples {
+		drop = len(e.window) - statsWindowSamples
+	}
+	for drop < len(e.window) && now.Sub(e.window[drop].at) > StatsWindow {
+		drop++
+	}
+
+	e.window = e.window[drop:]
+}
+
+func (c *statsCollector) snapshot() []Stats {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	stats := make([]Stats, 0, len(c.entries))
+
+	for key, entry := range c.entries {
+		entry.trim(now)
+
+		st := Stats{
+			Service:   key.service,
+			Type:      key.captchaType,
+			Successes: entry.successes,
+			Failures:  entry.failures,
+			Errors:    make(map[string]int, len(entry.errors)),
+			TotalCost: entry.cost,
+			Recent:    len(entry.window),
+		}
+
+		for class, n := range entry.errors {
+			st.Errors[class] = n
+		}
+
+		sorted := sortedDurations(entry.latencies)
+		st.P50 = percentile(sorted, 0.5)
+		st.P90 = percentile(sorted, 0.9)
+		st.P99 = percentile(sorted, 0.99)
+
+		if len(entry.window) > 0 {
+			recent := make([]time.Duration, 0, len(entry.window))
+			ok := 0
+			for _, sample := range entry.window {
+				recent = append(recent, sample.latency)
+				if sample.ok {
+					ok++
+				}
+			}
+
+			st.RecentSuccessRate = float64(ok) / float64(len(entry.window))
+			st.RecentP90 = percentile(sortedDurations(recent), 0.9)
+		}
+
+		stats = append(stats, st)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Type != stats[j].Type {
+			return stats[i].Type < stats[j].Type
+		}
+		return stats[i].Service < stats[j].Service
+	})
+
+	return stats
+}
+
+func sortedDurations(d []time.Duration) []time.Duration {
+	sorted := make([]time.Duration, len(d))
+	copy(sorted, d)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// percentile returns the p percentile of sorted, 0 if it's empty
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(p*float64(len(sorted)-1))]
+}
+
+// errorClasses names the sentinel errors in Stats.Errors
+var errorClasses = []struct {
+	err   error
+	class string
+}{
+	{ErrInvalidKey, "invalid_key"},
+	{ErrZeroBalance, "zero_balance"},
+	{ErrNoSlotAvailable, "no_slot"},
+	{ErrWrongSiteKey, "wrong_site_key"},
+	{ErrUnsolvable, "unsolvable"},
+	{ErrProxyBanned, "proxy_banned"},
+	{ErrRateLimited, "rate_limited"},
+	{ErrTaskNotFound, "task_not_found"},
+	{ErrMalformedResponse, "malformed"},
+	{ErrUnsupported, "unsupported"},
+	{ErrCircuitOpen, "circuit_open"},
+	{ErrBudgetExceeded, "budget_exceeded"},
+}
+
+// errorClass returns the class a failed solve is counted under
+func errorClass(err error) string {
+	var canceled *CanceledError
+	if errors.As(err, &canceled) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return "canceled"
+	}
+
+	for _, c := range errorClasses {
+		if errors.Is(err, c.err) {
+			return c.class
+		}
+	}
+
+	var serviceErr *ServiceError
+	if errors.As(err, &serviceErr) && serviceErr.Code != "" {
+		return strings.ToLower(strings.TrimPrefix(serviceErr.Code, "ERROR_"))
+	}
+
+	return "transport"
+}
@@ -0,0 +1,53 @@
+package captchago_test
+
+import (
+	"testing"
+
+	"github.com/median/captchago"
+	"github.com/median/captchago/captchagotest"
+)
+
+func TestStats(t *testing.T) {
+	srv := newServer(t)
+	srv.Script(captchago.CaptchaHCaptcha,
+		captchagotest.SolveAfter(0),
+		captchagotest.SolveAfter(1),
+		captchagotest.FailWith(0, "ERROR_CAPTCHA_UNSOLVABLE"),
+		captchagotest.FailCreate("ERROR_ZERO_BALANCE"),
+	)
+
+	solver := srv.Solver(captchago.TwoCaptcha)
+	solver.RetryPolicy.CreateAttempts = 1
+	solver.Prices = map[captchago.CaptchaType]float64{captchago.CaptchaHCaptcha: 0.003}
+
+	for i := 0; i < 4; i++ {
+		_, _ = solver.HCaptcha(captchago.HCaptchaOptions{PageURL: "https://example.com", SiteKey: "site-key"})
+	}
+
+	stats := solver.Stats()
+	if len(stats) != 1 {
+		t.Fatalf("%d stats, want 1", len(stats))
+	}
+
+	st := stats[0]
+	if st.Service != captchago.TwoCaptcha || st.Type != captchago.CaptchaHCaptcha {
+		t.Errorf("stats for %s %s", st.Service, st.Type)
+	}
+
+	if st.Successes != 2 || st.Failures != 2 || st.SuccessRate() != 0.5 {
+		t.Errorf("%d successes, %d failures", st.Successes, st.Failures)
+	}
+
+	if st.Errors["unsolvable"] != 1 || st.Errors["zero_balance"] != 1 {
+		t.Errorf("errors %v", st.Errors)
+	}
+
+	// failed solves cost nothing
+	if st.TotalCost != 0.006 {
+		t.Errorf("total cost %v, want 0.006", st.TotalCost)
+	}
+
+	if st.Recent != 4 || st.P99 < st.P50 {
+		t.Errorf("recent %d, p50 %s, p99 %s", st.Recent, st.P50, st.P99)
+	}
+}
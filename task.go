@@ -0,0 +1,337 @@
+package captchago
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	TaskStatusProcessing TaskStatus = "processing"
+	TaskStatusReady      TaskStatus = "ready"
+	TaskStatusFailed     TaskStatus = "failed"
+)
+
+// errSubmitted is returned by the solve methods when the task was only submitted, see Solver.Submit
+var errSubmitted = errors.New("task submitted")
+
+// TaskOptions is implemented by the options of every captcha type, so they can be solved with Solver.Solve.
+// Every type except Kasada can also be submitted as a task
+type TaskOptions interface {
+	captchaType() CaptchaType
+}
+
+func (RecaptchaV2Options) captchaType() CaptchaType  { return CaptchaRecaptchaV2 }
+func (RecaptchaV3Options) captchaType() CaptchaType  { return CaptchaRecaptchaV3 }
+func (HCaptchaOptions) captchaType() CaptchaType     { return CaptchaHCaptcha }
+func (FunCaptchaOptions) captchaType() CaptchaType   { return CaptchaFunCaptcha }
+func (CloudflareOptions) captchaType() CaptchaType   { return CaptchaCloudflare }
+func (ImageCaptchaOptions) captchaType() CaptchaType { return CaptchaImage }
+func (GeeTestOptions) captchaType() CaptchaType      { return CaptchaGeeTest }
+func (GeeTestV4Options) captchaType() CaptchaType    { return CaptchaGeeTestV4 }
+func (AmazonWAFOptions) captchaType() CaptchaType    { return CaptchaAmazonWAF }
+func (DataDomeOptions) captchaType() CaptchaType     { return CaptchaDataDome }
+func (AkamaiOptions) captchaType() CaptchaType       { return CaptchaAkamai }
+func (KasadaOptions) captchaType() CaptchaType       { return CaptchaKasada }
+
+// Submit creates a task without waiting for it to be solved, use Task.Wait to get the solution
+func (s *Solver) Submit(ctx context.Context, o TaskOptions) (*Task, error) {
+	// kasada is solved in a single request, there's no task to wait for
+	if _, ok := o.(KasadaOptions); ok {
+		return nil, s.unsupported("submitting kasada tasks")
+	}
+
+	sub := &submission{}
+
+	start := time.Now()
+	_, err := s.Solve(context.WithValue(ctx, submissionKey{}, sub), o)
+	if sub.taskId == nil {
+		if err == nil {
+			err = s.unsupported("submitting " + o.captchaType() + " tasks")
+		}
+
+		return nil, err
+	}
+
+	t := s.newTask(sub.taskId, o.captchaType())
+	t.Created = start
+	t.charge = sub.charge
+
+	// some services return the solution with the task
+	if sub.solution != nil {
+		t.finish(s.finishSolution(t, sub.solution))
+	}
+
+	return t, nil
+}
+
+// Resume returns a handle for a task that was submitted earlier, possibly by another process,
+// from the Task returned by Submit, usually stored as json. The solver must use the same service
+// and api key the task was submitted with, an error is returned if the service differs
+func (s *Solver) Resume(stored *Task) (*Task, error) {
+	if stored.Service != s.service {
+		return nil, fmt.Errorf("task %v was submitted to %s, not %s", stored.ID, stored.Service, s.service)
+	}
+
+	t := s.newTask(stored.ID, stored.Type)
+	if !stored.Created.IsZero() {
+		t.Created = stored.Created
+	}
+
+	return t, nil
+}
+
+// normalizeTaskId turns whole float64 task ids back into ints, json decodes every number as a float64
+func normalizeTaskId(id any) any {
+	if f, ok := id.(float64); ok && f == float64(int(f)) {
+		return int(f)
+	}
+
+	return id
+}
+
+// newTask returns a handle for a task of the solver's service
+func (s *Solver) newTask(id any, captchaType CaptchaType) *Task {
+	return &Task{
+		ID:      normalizeTaskId(id),
+		Service: s.service,
+		Type:    captchaType,
+		Created: time.Now(),
+		solver:  s,
+		status:  TaskStatusProcessing,
+		done:    make(chan struct{}),
+	}
+}
+
+// Solve solves o with the method for its captcha type.
+// Typed solutions like GeeTestSolution are returned as their embedded *Solution
+func (s *Solver) Solve(ctx context.Context, o TaskOptions) (*Solution, error) {
+	switch o := o.(type) {
+	case RecaptchaV2Options:
+		return s.RecaptchaV2Context(ctx, o)
+	case RecaptchaV3Options:
+		return s.RecaptchaV3Context(ctx, o)
+	case HCaptchaOptions:
+		return s.HCaptchaContext(ctx, o)
+	case FunCaptchaOptions:
+		return s.FunCaptchaContext(ctx, o)
+	case CloudflareOptions:
+		return s.CloudflareContext(ctx, o)
+	case ImageCaptchaOptions:
+		return s.ImageToTextContext(ctx, o)
+	case AmazonWAFOptions:
+		return s.AmazonWAFContext(ctx, o)
+	case DataDomeOptions:
+		return s.DataDomeContext(ctx, o)
+	case GeeTestOptions:
+		sol, err := s.GeeTestContext(ctx, o)
+		if err != nil {
+			return nil, err
+		}
+		return sol.Solution, nil
+	case GeeTestV4Options:
+		sol, err := s.GeeTestV4Context(ctx, o)
+		if err != nil {
+			return nil, err
+		}
+		return sol.Solution, nil
+	case AkamaiOptions:
+		sol, err := s.AkamaiContext(ctx, o)
+		if err != nil {
+			return nil, err
+		}
+		return sol.Solution, nil
+	case KasadaOptions:
+		sol, err := s.KasadaContext(ctx, o)
+		if err != nil {
+			return nil, err
+		}
+		return sol.Solution, nil
+	}
+
+	return nil, s.unsupported("solving " + o.captchaType())
+}
+
+// finishSolution fills in the fields a solve method would have set for the task's captcha type
+func (s *Solver) finishSolution(t *Task, sol *Solution) (*Solution, error) {
+	sol.TaskId = t.ID
+	sol.Speed = time.Since(t.Created).Milliseconds()
+	s.tagSolution(sol, t.Type)
+
+	switch t.Type {
+	case CaptchaAmazonWAF:
+		return amazonWAFSolution(sol)
+	case CaptchaDataDome:
+		return dataDomeSolution(sol)
+	case CaptchaAkamai:
+		return newAkamaiSolution(sol, AkamaiOptions{}).Solution, nil
+	}
+
+	return sol, nil
+}
+
+// waitTask polls the task until it is solved, fails or ctx is done
+func (s *Solver) waitTask(ctx context.Context, taskId any, poll func(context.Context, any) (*Solution, error)) (*Solution, error) {
+	policy := s.retryPolicy()
+	failures := 0
+
+	for {
+		if err := sleepContext(ctx, s.UpdateDelay); err != nil {
+			return nil, contextError(ctx, taskId, err)
+		}
+
+		s.log(LogLevelDebug, "polling task", "task_id", taskId)
+
+		sol, err := poll(ctx, taskId)
+		if err != nil {
+			failures++
+			if err := s.pollFailed(ctx, policy, taskId, err, failures); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		failures = 0
+
+		if sol != nil {
+			return sol, nil
+		}
+	}
+}
+
+// Status returns the last known status of the task, it's only updated by Poll and Wait
+func (t *Task) Status() TaskStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.status
+}
+
+// Done is closed once the task is solved or has failed
+func (t *Task) Done() <-chan struct{} {
+	return t.done
+}
+
+// Poll requests the task result once. It returns a nil solution and error while the task is processing
+func (t *Task) Poll(ctx context.Context) (*Solution, error) {
+	if sol, err, finished := t.result(); finished {
+		return sol, err
+	}
+
+	if t.solver.methods.TaskResult == nil {
+		return nil, t.solver.unsupported("polling tasks")
+	}
+
+	sol, err := t.solver.methods.TaskResult(ctx, t.ID)
+	if err != nil {
+		err = contextError(ctx, t.ID, err)
+
+		// errors from the service about the task itself mean it won't ever be solved
+		var serviceErr *ServiceError
+		if errors.As(err, &serviceErr) && (!serviceErr.Retryable() || errors.Is(err, ErrUnsolvable)) {
+			t.finish(nil, err)
+		}
+
+		return nil, err
+	}
+
+	if sol == nil {
+		return nil, nil
+	}
+
+	return t.finish(t.solver.finishSolution(t, sol))
+}
+
+// Wait polls the task until it's solved, has failed or ctx is done.
+// The solver's UpdateDelay and RetryPolicy are used like in the blocking methods
+func (t *Task) Wait(ctx context.Context) (*Solution, error) {
+	if sol, err, finished := t.result(); finished {
+		return sol, err
+	}
+
+	sol, err := t.solver.waitTask(ctx, t.ID, func(ctx context.Context, _ any) (*Solution, error) {
+		return t.Poll(ctx)
+	})
+
+	// the task is only left processing if ctx is done, anything else means polling gave up on it
+	if err != nil && ctx.Err() == nil {
+		return t.finish(nil, err)
+	}
+
+	return sol, err
+}
+
+// result returns the outcome of the task if it has finished
+func (t *Task) result() (*Solution, error, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.solution, t.err, t.status != TaskStatusProcessing
+}
+
+// finish stores the outcome of the task, only the first call has an effect
+func (t *Task) finish(sol *Solution, err error) (*Solution, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.status != TaskStatusProcessing {
+		return t.solution, t.err
+	}
+
+	t.solution = sol
+	t.err = err
+	t.status = TaskStatusReady
+	if err != nil {
+		t.status = TaskStatusFailed
+	}
+
+	cost := 0.0
+	if err == nil {
+		cost = t.solver.taskCost(t.Type, sol)
+	}
+	t.charge.settle(cost, err)
+	t.solver.stats.record(t.Service, t.Type, time.Since(t.Created), cost, err)
+
+	close(t.done)
+	return sol, err
+}
+
+func submissionFrom(ctx context.Context) *submission {
+	sub, _ := ctx.Value(submissionKey{}).(*submission)
+	return sub
+}
+
+// Task is a handle for a submitted task. ID, Service, Type and Created are all that's needed to resume
+// it with Solver.Resume, so the task can be stored as json and picked up by another process
+type Task struct {
+	ID      any          `json:"id"`
+	Service SolveService `json:"service"`
+	Type    CaptchaType  `json:"type"`
+
+	// Created is when the task was submitted
+	Created time.Time `json:"created"`
+
+	solver *Solver
+
+	// charge is the budget charge of the task, nil if it was resumed or the solver has no budget
+	charge *budgetCharge
+
+	mu       sync.Mutex
+	status   TaskStatus
+	solution *Solution
+	err      error
+	done     chan struct{}
+}
+
+// submission is stored in the context by Submit to make the solve methods return once the task is created
+type submission struct {
+	taskId   any
+	solution *Solution
+	charge   *budgetCharge
+}
+
+type submissionKey struct{}
+
+type TaskStatus = string
@@ -0,0 +1,159 @@
+package captchago_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/median/captchago"
+	"github.com/median/captchago/captchagotest"
+)
+
+func TestSubmitResumeJSON(t *testing.T) {
+	for _, service := range []captchago.SolveService{captchago.AntiCaptcha, captchago.TwoCaptcha} {
+		t.Run(service, func(t *testing.T) {
+			srv := newServer(t)
+			srv.Script(captchago.CaptchaHCaptcha, captchagotest.SolveAfter(3))
+
+			task, err := srv.Solver(service).Submit(context.Background(), captchago.HCaptchaOptions{PageURL: "https://example.com", SiteKey: "site-key"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := json.Marshal(task)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// another process picks the task up
+			var stored captchago.Task
+			if err := json.Unmarshal(data, &stored); err != nil {
+				t.Fatal(err)
+			}
+
+			resumed, err := srv.Solver(service).Resume(&stored)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resumed.ID != task.ID || resumed.Type != captchago.CaptchaHCaptcha || !resumed.Created.Equal(task.Created) {
+				t.Errorf("resumed %+v, submitted %+v", resumed, task)
+			}
+
+			sol, err := resumed.Wait(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if sol.Text != captchagotest.Token || sol.TaskId != task.ID {
+				t.Errorf("solution %+v", sol)
+			}
+
+			if resumed.Status() != captchago.TaskStatusReady {
+				t.Errorf("status %s, want ready", resumed.Status())
+			}
+		})
+	}
+}
+
+func TestResumeOtherService(t *testing.T) {
+	srv := newServer(t)
+
+	task, err := srv.Solver(captchago.AntiCaptcha).Submit(context.Background(), captchago.RecaptchaV2Options{PageURL: "https://example.com", SiteKey: "site-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := srv.Solver(captchago.TwoCaptcha).Resume(task); err == nil {
+		t.Fatal("resumed an anticaptcha task with a 2captcha solver")
+	}
+}
+
+func TestTaskPoll(t *testing.T) {
+	srv := newServer(t)
+	srv.Script(captchago.CaptchaRecaptchaV2, captchagotest.SolveAfter(1))
+
+	task, err := srv.Solver(captchago.AntiCaptcha).Submit(context.Background(), captchago.RecaptchaV2Options{PageURL: "https://example.com", SiteKey: "site-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sol, err := task.Poll(context.Background())
+	if sol != nil || err != nil || task.Status() != captchago.TaskStatusProcessing {
+		t.Fatalf("first poll: %v, %v, %s", sol, err, task.Status())
+	}
+
+	sol, err = task.Poll(context.Background())
+	if err != nil || sol == nil {
+		t.Fatalf("second poll: %v, %v", sol, err)
+	}
+
+	select {
+	case <-task.Done():
+	default:
+		t.Fatal("done isn't closed")
+	}
+}
+
+func TestTaskWaitGivesUp(t *testing.T) {
+	srv := newServer(t)
+	srv.Script(captchago.CaptchaHCaptcha, captchagotest.Malformed("{"))
+
+	solver := srv.Solver(captchago.AntiCaptcha)
+	solver.RetryPolicy.MaxPollFailures = 2
+
+	task, err := solver.Submit(context.Background(), captchago.HCaptchaOptions{PageURL: "https://example.com", SiteKey: "site-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = task.Wait(context.Background())
+	if err == nil {
+		t.Fatal("malformed result solved the task")
+	}
+
+	select {
+	case <-task.Done():
+	default:
+		t.Fatal("done isn't closed")
+	}
+
+	if task.Status() != captchago.TaskStatusFailed {
+		t.Errorf("status %s, want failed", task.Status())
+	}
+
+	// the outcome is kept
+	if _, again := task.Wait(context.Background()); !errors.Is(again, err) {
+		t.Errorf("second wait returned %v, first %v", again, err)
+	}
+}
+
+func TestTaskWaitCanceled(t *testing.T) {
+	srv := newServer(t)
+	srv.Script(captchago.CaptchaHCaptcha, captchagotest.SolveAfter(1000))
+
+	task, err := srv.Solver(captchago.AntiCaptcha).Submit(context.Background(), captchago.HCaptchaOptions{PageURL: "https://example.com", SiteKey: "site-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := task.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+
+	// the task can still be waited for with another context
+	if task.Status() != captchago.TaskStatusProcessing {
+		t.Errorf("status %s, want processing", task.Status())
+	}
+}
+
+func TestSubmitKasada(t *testing.T) {
+	_, err := newServer(t).Solver(captchago.CapSolver).Submit(context.Background(), captchago.KasadaOptions{PageURL: "https://example.com", Proxy: testProxy})
+	if !errors.Is(err, captchago.ErrUnsupported) {
+		t.Fatalf("got %v, want ErrUnsupported", err)
+	}
+}
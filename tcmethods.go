@@ -1,13 +1,41 @@
 package captchago
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
-	"fmt"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// twoCaptchaError converts a raw error body like "ERROR_ZERO_BALANCE" into a *ServiceError
+func twoCaptchaError(service SolveService, body string, taskId any) error {
+	code := strings.TrimSpace(body)
+
+	// some errors come with extra information, like "ERROR_BAD_PARAMETERS|..."
+	description := code
+	if i := strings.Index(code, "|"); i != -1 {
+		code = code[:i]
+	}
+
+	// anything that isn't an error code, like the html page of a gateway error, means the service is unreachable
+	// known codes like "ERROR: 1001" are checked first, they aren't made of code runes
+	_, known := errorCodes[code]
+	if !known && (code == "" || strings.TrimFunc(code, isCodeRune) != "") {
+		if len(description) > 100 {
+			description = description[:100] + "..."
+		}
+		return malformed(description)
+	}
+
+	return newServiceError(service, code, description, taskId)
+}
+
+func isCodeRune(r rune) bool {
+	return r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_'
+}
+
 func twoCaptchaMethods(solver *Solver, preferredDomain string) *solveMethods {
 	domain := func() string {
 		r := preferredDomain
@@ -15,31 +43,50 @@ func twoCaptchaMethods(solver *Solver, preferredDomain string) *solveMethods {
 			r = solver.ForcedDomain
 		}
 
-		if strings.Contains(r, ":") || strings.Count(r, ".") == 4 {
-			r = "http://" + r
-		} else {
-			r = "https://" + r
+		if !strings.Contains(r, "://") {
+			if strings.Contains(r, ":") || strings.Count(r, ".") == 4 {
+				r = "http://" + r
+			} else {
+				r = "https://" + r
+			}
 		}
 
 		return r
 	}
 
-	createTask := func(base map[string]interface{}) (int, error) {
+	createTask := func(ctx context.Context, base map[string]interface{}) (taskId int, err error) {
 		d := domain()
 
+		charge := chargeFrom(ctx)
+		if err := charge.reserve(); err != nil {
+			return 0, err
+		}
+		defer func() {
+			if err != nil {
+				charge.release()
+			}
+		}()
+
+		breaker := solver.circuit()
+		if err := solver.allowRequest(breaker); err != nil {
+			return 0, err
+		}
+		defer func() { solver.requestDone(breaker, err) }()
+
 		base["key"] = solver.ApiKey
 		base["soft_id"] = 3891
 
-		body, err := postQuery(d+"/in.php", base)
+		// in.php is sent as a form so large payloads like images fit
+		body, err := postForm(ctx, solver.httpClient(), d+"/in.php", base)
 		if err != nil {
-			return 0, err
+			return 0, contextError(ctx, nil, err)
 		}
 
-		if !strings.Contains(body, "|") {
-			return 0, errors.New(body)
+		if !strings.HasPrefix(body, "OK|") {
+			return 0, twoCaptchaError(solver.service, body, nil)
 		}
 
-		taskId, err := strconv.Atoi(strings.Split(body, "|")[1])
+		taskId, err = strconv.Atoi(strings.Split(body, "|")[1])
 		if err != nil {
 			return 0, err
 		}
@@ -47,81 +94,95 @@ func twoCaptchaMethods(solver *Solver, preferredDomain string) *solveMethods {
 		return taskId, nil
 	}
 
-	getResponse := func(taskId int) (*Solution, error) {
-		for {
-			time.Sleep(solver.UpdateDelay)
+	// pollTask requests the result of a task once, the solution is nil while the task is processing
+	pollTask := func(ctx context.Context, taskId any) (*Solution, error) {
+		body, err := postQuery(ctx, solver.httpClient(), domain()+"/res.php", map[string]interface{}{
+			"key":    solver.ApiKey,
+			"action": "get",
+			"id":     taskId,
+		})
+		if err != nil {
+			return nil, err
+		}
 
-			if solver.Verbose {
-				fmt.Println("getting response for task", taskId)
-			}
+		if strings.Contains(body, "CAPCHA_NOT_READY") {
+			return nil, nil
+		}
 
-			body, err := postQuery(domain()+"/res.php", map[string]interface{}{
-				"key":    solver.ApiKey,
-				"action": "get",
-				"id":     taskId,
-			})
-			if err != nil {
-				return nil, err
-			}
+		if !strings.Contains(body, "OK|") {
+			return nil, twoCaptchaError(solver.service, body, taskId)
+		}
 
-			if strings.Contains(body, "CAPCHA_NOT_READY") {
-				continue
-			}
+		sol := &Solution{
+			Text:   strings.TrimPrefix(body, "OK|"),
+			TaskId: taskId,
+		}
 
-			if !strings.Contains(body, "OK|") {
-				return nil, errors.New(body)
+		// some tasks answer with a json object, like geetest
+		if strings.HasPrefix(sol.Text, "{") {
+			if err := json.Unmarshal([]byte(sol.Text), &sol.RawSolution); err != nil {
+				return nil, malformed("invalid json solution")
 			}
-
-			return &Solution{
-				Text:   strings.TrimPrefix(body, "OK|"),
-				TaskId: taskId,
-			}, nil
 		}
+
+		return sol, nil
 	}
 
-	createResponse := func(taskData map[string]interface{}) (*Solution, error) {
-		start := time.Now().UnixMilli()
-		taskId, err := createTask(taskData)
+	createResponse := func(ctx context.Context, taskData map[string]interface{}) (*Solution, error) {
+		start := time.Now()
+		taskType := taskData["method"]
+
+		taskId, err := retryCreate(ctx, solver.retryPolicy(), func() (int, error) {
+			return createTask(ctx, taskData)
+		})
 		if err != nil {
+			solver.log(LogLevelError, "task failed", "type", taskType, "error", err, "elapsed", time.Since(start))
 			return nil, err
 		}
 
-		if solver.Verbose {
-			fmt.Printf("created task with id %v\n", taskId)
+		solver.log(LogLevelInfo, "task created", "type", taskType, "task_id", taskId)
+
+		if sub := submissionFrom(ctx); sub != nil {
+			sub.taskId = taskId
+			return nil, errSubmitted
 		}
 
-		sol, err := getResponse(taskId)
+		sol, err := solver.waitTask(ctx, taskId, pollTask)
 		if sol != nil {
-			sol.Speed = time.Now().UnixMilli() - start
+			sol.Speed = time.Since(start).Milliseconds()
 		}
 
-		if solver.Verbose && err == nil {
-			fmt.Printf("solved task with id %v\n", taskId)
+		if err != nil {
+			solver.log(LogLevelError, "task failed", "type", taskType, "task_id", taskId, "error", err, "elapsed", time.Since(start))
+		} else {
+			solver.log(LogLevelInfo, "task solved", "type", taskType, "task_id", taskId, "elapsed", time.Since(start))
 		}
 
 		return sol, err
 	}
 
 	return &solveMethods{
-		GetBalance: func() (float64, error) {
+		domain:     domain,
+		TaskResult: pollTask,
+		GetBalance: func(ctx context.Context) (float64, error) {
 			d := domain()
 
-			body, err := postQuery(d+"/res.php", map[string]interface{}{
+			body, err := postQuery(ctx, solver.httpClient(), d+"/res.php", map[string]interface{}{
 				"key":    solver.ApiKey,
 				"action": "getbalance",
 			})
 			if err != nil {
-				return 0, err
+				return 0, contextError(ctx, nil, err)
 			}
 
 			parsed, err := strconv.ParseFloat(body, 64)
 			if err != nil {
-				return 0, errors.New(body)
+				return 0, twoCaptchaError(solver.service, body, nil)
 			}
 
 			return parsed, nil
 		},
-		RecaptchaV2: func(o RecaptchaV2Options) (*Solution, error) {
+		RecaptchaV2: func(ctx context.Context, o RecaptchaV2Options) (*Solution, error) {
 			payload := map[string]interface{}{
 				"method":    "userrecaptcha",
 				"googlekey": o.SiteKey,
@@ -157,9 +218,9 @@ func twoCaptchaMethods(solver *Solver, preferredDomain string) *solveMethods {
 				payload["proxytype"] = strings.ToUpper(o.Proxy.pType)
 			}
 
-			return createResponse(payload)
+			return createResponse(ctx, payload)
 		},
-		HCaptcha: func(o HCaptchaOptions) (*Solution, error) {
+		HCaptcha: func(ctx context.Context, o HCaptchaOptions) (*Solution, error) {
 			payload := map[string]interface{}{
 				"method":  "hcaptcha",
 				"sitekey": o.SiteKey,
@@ -183,9 +244,9 @@ func twoCaptchaMethods(solver *Solver, preferredDomain string) *solveMethods {
 				payload["data"] = o.EnterprisePayload.RQData
 			}
 
-			return createResponse(payload)
+			return createResponse(ctx, payload)
 		},
-		FunCaptcha: func(o FunCaptchaOptions) (*Solution, error) {
+		FunCaptcha: func(ctx context.Context, o FunCaptchaOptions) (*Solution, error) {
 			payload := map[string]interface{}{
 				"method":    "funcaptcha",
 				"publickey": o.PublicKey,
@@ -205,9 +266,9 @@ func twoCaptchaMethods(solver *Solver, preferredDomain string) *solveMethods {
 				payload["proxytype"] = strings.ToUpper(o.Proxy.pType)
 			}
 
-			return createResponse(payload)
+			return createResponse(ctx, payload)
 		},
-		RecaptchaV3: func(o RecaptchaV3Options) (*Solution, error) {
+		RecaptchaV3: func(ctx context.Context, o RecaptchaV3Options) (*Solution, error) {
 			payload := map[string]interface{}{
 				"method":    "userrecaptcha",
 				"version":   "v3",
@@ -227,9 +288,9 @@ func twoCaptchaMethods(solver *Solver, preferredDomain string) *solveMethods {
 				payload["enterprise"] = 1
 			}
 
-			return createResponse(payload)
+			return createResponse(ctx, payload)
 		},
-		Cloudflare: func(o CloudflareOptions) (*Solution, error) {
+		Cloudflare: func(ctx context.Context, o CloudflareOptions) (*Solution, error) {
 			payload := map[string]interface{}{
 				"method":  "turnstile",
 				"sitekey": o.SiteKey,
@@ -250,7 +311,184 @@ func twoCaptchaMethods(solver *Solver, preferredDomain string) *solveMethods {
 				}
 			}
 
-			return createResponse(payload)
+			return createResponse(ctx, payload)
+		},
+		GeeTest: func(ctx context.Context, o GeeTestOptions) (*GeeTestSolution, error) {
+			payload := map[string]interface{}{
+				"method":    "geetest",
+				"gt":        o.GT,
+				"challenge": o.Challenge,
+				"pageurl":   o.PageURL,
+			}
+
+			if o.APIServer != "" {
+				payload["api_server"] = o.APIServer
+			}
+
+			if o.UserAgent != "" {
+				payload["userAgent"] = o.UserAgent
+			}
+
+			if o.Proxy != nil {
+				payload["proxy"] = o.Proxy.String()
+				payload["proxytype"] = strings.ToUpper(o.Proxy.pType)
+			}
+
+			sol, err := createResponse(ctx, payload)
+			if err != nil {
+				return nil, err
+			}
+
+			return &GeeTestSolution{
+				Solution:  sol,
+				Challenge: rawString(sol.RawSolution, "geetest_challenge"),
+				Validate:  rawString(sol.RawSolution, "geetest_validate"),
+				Seccode:   rawString(sol.RawSolution, "geetest_seccode"),
+			}, nil
+		},
+		GeeTestV4: func(ctx context.Context, o GeeTestV4Options) (*GeeTestV4Solution, error) {
+			payload := map[string]interface{}{
+				"method":     "geetest_v4",
+				"captcha_id": o.CaptchaID,
+				"pageurl":    o.PageURL,
+			}
+
+			if o.APIServer != "" {
+				payload["api_server"] = o.APIServer
+			}
+
+			if o.UserAgent != "" {
+				payload["userAgent"] = o.UserAgent
+			}
+
+			if o.Proxy != nil {
+				payload["proxy"] = o.Proxy.String()
+				payload["proxytype"] = strings.ToUpper(o.Proxy.pType)
+			}
+
+			sol, err := createResponse(ctx, payload)
+			if err != nil {
+				return nil, err
+			}
+
+			return newGeeTestV4Solution(sol, o.CaptchaID), nil
+		},
+		AmazonWAF: func(ctx context.Context, o AmazonWAFOptions) (*Solution, error) {
+			payload := map[string]interface{}{
+				"method":           "amazon_waf",
+				"sitekey":          o.SiteKey,
+				"iv":               o.Iv,
+				"context":          o.Context,
+				"pageurl":          o.PageURL,
+				"challenge_script": o.ChallengeScript,
+			}
+
+			if o.CaptchaScript != "" {
+				payload["captcha_script"] = o.CaptchaScript
+			}
+
+			if o.UserAgent != "" {
+				payload["userAgent"] = o.UserAgent
+			}
+
+			if o.Proxy != nil {
+				payload["proxy"] = o.Proxy.String()
+				payload["proxytype"] = strings.ToUpper(o.Proxy.pType)
+			}
+
+			sol, err := createResponse(ctx, payload)
+			if err != nil {
+				return nil, err
+			}
+
+			return amazonWAFSolution(sol)
+		},
+		DataDome: func(ctx context.Context, o DataDomeOptions) (*Solution, error) {
+			if o.Proxy == nil {
+				return nil, errors.New("proxy is required")
+			}
+
+			payload := map[string]interface{}{
+				"method":      "datadome",
+				"captcha_url": o.CaptchaURL,
+				"pageurl":     o.PageURL,
+				"userAgent":   o.UserAgent,
+				"proxy":       o.Proxy.String(),
+				"proxytype":   strings.ToUpper(o.Proxy.pType),
+			}
+
+			sol, err := createResponse(ctx, payload)
+			if err != nil {
+				return nil, err
+			}
+
+			return dataDomeSolution(sol)
+		},
+		ImageToText: func(ctx context.Context, o ImageCaptchaOptions) (*Solution, error) {
+			image, err := o.base64()
+			if err != nil {
+				return nil, err
+			}
+
+			payload := map[string]interface{}{
+				"method": "base64",
+				"body":   image,
+			}
+
+			if o.Phrase {
+				payload["phrase"] = 1
+			}
+
+			if o.CaseSensitive {
+				payload["regsense"] = 1
+			}
+
+			if o.Numeric != 0 {
+				payload["numeric"] = o.Numeric
+			}
+
+			if o.Math {
+				payload["calc"] = 1
+			}
+
+			if o.MinLength > 0 {
+				payload["min_len"] = o.MinLength
+			}
+
+			if o.MaxLength > 0 {
+				payload["max_len"] = o.MaxLength
+			}
+
+			if o.Language != "" {
+				payload["lang"] = o.Language
+			}
+
+			if o.Comment != "" {
+				payload["textinstructions"] = o.Comment
+			}
+
+			return createResponse(ctx, payload)
+		},
+		Report: func(ctx context.Context, sol *Solution, correct bool) error {
+			action := "reportbad"
+			if correct {
+				action = "reportgood"
+			}
+
+			body, err := postQuery(ctx, solver.httpClient(), domain()+"/res.php", map[string]interface{}{
+				"key":    solver.ApiKey,
+				"action": action,
+				"id":     sol.TaskId,
+			})
+			if err != nil {
+				return contextError(ctx, sol.TaskId, err)
+			}
+
+			if !strings.HasPrefix(body, "OK_REPORT_RECORDED") {
+				return twoCaptchaError(solver.service, body, sol.TaskId)
+			}
+
+			return nil
 		},
 	}
 }
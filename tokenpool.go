@@ -0,0 +1,341 @@
+package captchago
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrPoolStopped is returned by TokenPool.Get once the pool has stopped solving and has no tokens left
+var ErrPoolStopped = errors.New("token pool stopped")
+
+// DefaultTokenTTL returns how long a token of the captcha type can be used for.
+// It's a bit shorter than the real lifetime so tokens aren't handed out right before they expire
+func DefaultTokenTTL(captchaType CaptchaType) time.Duration {
+	switch captchaType {
+	case CaptchaRecaptchaV2, CaptchaRecaptchaV3, CaptchaHCaptcha:
+		return time.Second * 110
+	case CaptchaCloudflare:
+		return time.Second * 290
+	}
+
+	return time.Second * 60
+}
+
+// NewTokenPool starts solving o in the background so tokens are ready before they're needed.
+// Close must be called once the pool isn't used anymore
+func NewTokenPool(s *Solver, o TaskOptions, opts TokenPoolOptions) *TokenPool {
+	if opts.Size < 1 {
+		opts.Size = 1
+	}
+
+	if opts.MaxSize < opts.Size {
+		opts.MaxSize = opts.Size
+	}
+
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTokenTTL(o.captchaType())
+	}
+
+	if opts.MaxConsecutiveErrors == 0 {
+		opts.MaxConsecutiveErrors = 5
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p := &TokenPool{
+		solver:  s,
+		task:    o,
+		opts:    opts,
+		ctx:     ctx,
+		cancel:  cancel,
+		changed: make(chan struct{}),
+		wake:    make(chan struct{}, 1),
+	}
+
+	p.wg.Add(1)
+	go p.refill()
+
+	return p
+}
+
+// Get returns a fresh token, waiting for one to be solved if the pool is empty.
+// Every token is only returned once
+func (p *TokenPool) Get(ctx context.Context) (*Solution, error) {
+	p.mu.Lock()
+	p.demand = append(p.demand, time.Now())
+	p.mu.Unlock()
+	p.signal()
+
+	for {
+		p.mu.Lock()
+		p.dropExpired()
+
+		if len(p.tokens) > 0 {
+			t := p.tokens[0]
+			p.tokens = p.tokens[1:]
+			p.mu.Unlock()
+
+			p.signal()
+			return t.sol, nil
+		}
+
+		if p.err != nil && p.inFlight == 0 {
+			err := p.err
+			p.mu.Unlock()
+			return nil, err
+		}
+
+		changed := p.changed
+		p.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, contextError(ctx, nil, ctx.Err())
+		}
+	}
+}
+
+// Len returns the number of fresh tokens ready to be used
+func (p *TokenPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.dropExpired()
+	return len(p.tokens)
+}
+
+// Err returns why the pool stopped solving, nil while it's running
+func (p *TokenPool) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.err
+}
+
+// Close stops solving and cancels every task in progress, tokens left in the pool are discarded
+func (p *TokenPool) Close() {
+	p.mu.Lock()
+	p.stop(fmt.Errorf("%w: closed", ErrPoolStopped))
+	p.tokens = nil
+	p.mu.Unlock()
+
+	p.cancel()
+	p.wg.Wait()
+}
+
+// refill keeps enough tasks running to reach the target size until the pool stops
+func (p *TokenPool) refill() {
+	defer p.wg.Done()
+
+	// expired tokens are checked every so often even without any activity
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		p.mu.Lock()
+		p.dropExpired()
+
+		// after a failed solve no new ones are started until the backoff is over
+		backoff := time.Until(p.retryAt)
+
+		for backoff <= 0 && p.err == nil && len(p.tokens)+p.inFlight < p.target() {
+			if p.opts.MaxSolves > 0 && p.solves >= p.opts.MaxSolves {
+				p.stop(fmt.Errorf("%w: solve budget of %d reached", ErrPoolStopped, p.opts.MaxSolves))
+				break
+			}
+
+			p.inFlight++
+			p.solves++
+
+			p.wg.Add(1)
+			go p.solveOne(time.Now())
+		}
+		p.mu.Unlock()
+
+		// a nil channel blocks, so there's only something to wait for during a backoff
+		var retry <-chan time.Time
+		var timer *time.Timer
+		if backoff > 0 {
+			timer = time.NewTimer(backoff)
+			retry = timer.C
+		}
+
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-p.wake:
+		case <-ticker.C:
+		case <-retry:
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// solveOne solves a token for the pool, started is when the refill loop started it
+func (p *TokenPool) solveOne(started time.Time) {
+	defer p.wg.Done()
+
+	sol, err := p.solver.Solve(p.ctx, p.task)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.inFlight--
+
+	if err != nil {
+		// solves started before the last counted failure ran into the same problem, they don't count again
+		if !started.Before(p.failedAt) {
+			p.errors++
+			p.failedAt = time.Now()
+			p.retryAt = p.failedAt.Add(p.solver.retryPolicy().backoff(p.errors))
+		}
+
+		if p.opts.MaxConsecutiveErrors > 0 && p.errors >= p.opts.MaxConsecutiveErrors {
+			p.stop(fmt.Errorf("%w: %d errors in a row, last error: %s", ErrPoolStopped, p.errors, err))
+		}
+
+		p.broadcast()
+		p.signal()
+		return
+	}
+
+	p.errors = 0
+	p.retryAt = time.Time{}
+
+	// keep a moving average of how long solves take to size the pool
+	latency := time.Duration(sol.Speed) * time.Millisecond
+	if p.latency == 0 {
+		p.latency = latency
+	} else {
+		p.latency = (p.latency*4 + latency) / 5
+	}
+
+	if p.err == nil {
+		p.tokens = append(p.tokens, pooledToken{
+			sol:     sol,
+			expires: time.Now().Add(p.opts.TTL),
+		})
+	}
+
+	p.broadcast()
+}
+
+// target returns how many tokens should be ready or in progress, based on how many were requested recently.
+// p.mu must be held
+func (p *TokenPool) target() int {
+	window := time.Minute
+	cutoff := time.Now().Add(-window)
+
+	i := 0
+	for i < len(p.demand) && p.demand[i].Before(cutoff) {
+		i++
+	}
+	p.demand = p.demand[i:]
+
+	// enough tokens to cover the requests expected while a solve is running
+	rate := float64(len(p.demand)) / window.Seconds()
+	needed := int(math.Ceil(rate * p.latency.Seconds()))
+
+	if needed < p.opts.Size {
+		return p.opts.Size
+	}
+
+	if needed > p.opts.MaxSize {
+		return p.opts.MaxSize
+	}
+
+	return needed
+}
+
+// dropExpired removes tokens that are too old to be used, p.mu must be held
+func (p *TokenPool) dropExpired() {
+	now := time.Now()
+
+	i := 0
+	for i < len(p.tokens) && now.After(p.tokens[i].expires) {
+		i++
+	}
+
+	if i > 0 {
+		p.tokens = p.tokens[i:]
+		p.signal()
+	}
+}
+
+// stop stops the pool from solving more tokens, p.mu must be held
+func (p *TokenPool) stop(err error) {
+	if p.err == nil {
+		p.err = err
+		p.broadcast()
+	}
+}
+
+// broadcast wakes up everyone waiting in Get, p.mu must be held
+func (p *TokenPool) broadcast() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// signal wakes up the refill loop
+func (p *TokenPool) signal() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// TokenPool keeps tokens for one captcha solved ahead of time, see NewTokenPool
+type TokenPool struct {
+	solver *Solver
+	task   TaskOptions
+	opts   TokenPoolOptions
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	tokens   []pooledToken
+	inFlight int
+	solves   int
+	errors   int
+	failedAt time.Time
+	retryAt  time.Time
+	err      error
+	latency  time.Duration
+	demand   []time.Time
+	changed  chan struct{}
+	wake     chan struct{}
+}
+
+type TokenPoolOptions struct {
+	// Size is the number of tokens kept ready when there's little demand, defaults to 1
+	Size int
+
+	// MaxSize is the most tokens kept ready or in progress when demand goes up, defaults to Size
+	MaxSize int
+
+	// TTL is how long a token can be handed out after it was solved, defaults to DefaultTokenTTL
+	TTL time.Duration
+
+	// MaxSolves stops the pool after this many tasks were started, 0 means no limit
+	MaxSolves int
+
+	// MaxConsecutiveErrors stops the pool after this many failed solves in a row, defaults to 5.
+	// After a failure new solves wait for the backoff of the solver's RetryPolicy, so the errors are spread out.
+	// A negative value never stops the pool
+	MaxConsecutiveErrors int
+}
+
+type pooledToken struct {
+	sol     *Solution
+	expires time.Time
+}
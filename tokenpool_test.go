@@ -0,0 +1,100 @@
+package captchago_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/median/captchago"
+	"github.com/median/captchago/captchagotest"
+)
+
+func TestTokenPool(t *testing.T) {
+	srv := newServer(t)
+
+	pool := captchago.NewTokenPool(srv.Solver(captchago.AntiCaptcha), captchago.RecaptchaV2Options{PageURL: "https://example.com", SiteKey: "site-key"}, captchago.TokenPoolOptions{Size: 2})
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	seen := map[interface{}]bool{}
+	for i := 0; i < 3; i++ {
+		sol, err := pool.Get(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if seen[sol.TaskId] {
+			t.Fatalf("token of task %v was returned twice", sol.TaskId)
+		}
+		seen[sol.TaskId] = true
+	}
+
+	// the pool refills itself
+	deadline := time.Now().Add(time.Second * 5)
+	for pool.Len() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("pool has %d tokens, want 2", pool.Len())
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+}
+
+func TestTokenPoolStops(t *testing.T) {
+	srv := newServer(t)
+	srv.SetDefault(captchagotest.FailCreate("ERROR_ZERO_BALANCE"))
+
+	pool := captchago.NewTokenPool(srv.Solver(captchago.AntiCaptcha), captchago.HCaptchaOptions{PageURL: "https://example.com", SiteKey: "site-key"}, captchago.TokenPoolOptions{MaxConsecutiveErrors: 2})
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	_, err := pool.Get(ctx)
+	if !errors.Is(err, captchago.ErrPoolStopped) {
+		t.Fatalf("got %v, want ErrPoolStopped", err)
+	}
+
+	if !errors.Is(pool.Err(), captchago.ErrPoolStopped) {
+		t.Errorf("Err() returned %v", pool.Err())
+	}
+}
+
+func TestTokenPoolBackoff(t *testing.T) {
+	srv := newServer(t)
+	srv.SetDefault(captchagotest.FailCreate("ERROR_NO_SLOT_AVAILABLE"))
+
+	solver := srv.Solver(captchago.AntiCaptcha)
+	solver.RetryPolicy.CreateAttempts = 1
+	solver.RetryPolicy.BaseDelay = time.Millisecond * 10
+	solver.RetryPolicy.MaxDelay = time.Millisecond * 20
+
+	task := captchago.HCaptchaOptions{PageURL: "https://example.com", SiteKey: "site-key"}
+
+	// a pool that never stops waits between failed solves instead of hammering the service
+	pool := captchago.NewTokenPool(solver, task, captchago.TokenPoolOptions{MaxConsecutiveErrors: -1})
+	time.Sleep(time.Millisecond * 200)
+	pool.Close()
+
+	if n := countPath(srv, "/createTask"); n < 2 || n > 20 {
+		t.Errorf("%d tasks created in 200ms, want about 10", n)
+	}
+
+	// the errors that stop the pool are spread over the backoff, 10ms then 20ms
+	start := time.Now()
+	pool = captchago.NewTokenPool(solver, task, captchago.TokenPoolOptions{MaxConsecutiveErrors: 3})
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	if _, err := pool.Get(ctx); !errors.Is(err, captchago.ErrPoolStopped) {
+		t.Fatalf("got %v, want ErrPoolStopped", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < time.Millisecond*25 {
+		t.Errorf("pool stopped after %v, before backing off", elapsed)
+	}
+}